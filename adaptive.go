@@ -0,0 +1,76 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+// AIMD configures the additive increase and multiplicative decrease of
+// the limit of a Throttle. After each successful task the limit is raised
+// by Increase, after each task failing because of an overload it is
+// multiplied with Decrease. The limit always stays between Min and Max.
+type AIMD struct {
+	// Min is the lowest limit, it has to be positive so that the limit can
+	// be increased again. Otherwise 1 is used.
+	Min Limit
+
+	// Max is the highest limit, 0 means no upper bound.
+	Max Limit
+
+	// Increase is added to the limit after a successful task.
+	Increase Limit
+
+	// Decrease is the factor between 0 and 1 the limit is multiplied
+	// with after an overload. Otherwise 0.5 is used.
+	Decrease float64
+
+	// IsOverload classifies the error of a task. If it returns true the
+	// limit is decreased, otherwise the limit stays unchanged. If it is
+	// nil every error is an overload.
+	IsOverload func(err error) bool
+}
+
+// WithAIMD lets the throttle adapt its limit to the errors returned by the
+// processed tasks. This protects downstream services without hand-tuning
+// the limit. The initial limit is the one the throttle has been created with,
+// kept between the minimum and the maximum.
+func WithAIMD(aimd AIMD) ThrottleOption {
+	return func(t *Throttle) {
+		if aimd.Min <= 0 {
+			aimd.Min = 1
+		}
+		if aimd.Decrease <= 0 || aimd.Decrease >= 1 {
+			aimd.Decrease = 0.5
+		}
+		if aimd.Max <= 0 {
+			aimd.Max = InfLimit
+		}
+		t.aimd = &aimd
+		t.limiter.SetLimit(t.clamp(t.limiter.Limit()))
+	}
+}
+
+
+// adapt changes the limit depending on the error of a processed task.
+func (t *Throttle) adapt(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limit := t.limiter.Limit()
+	switch {
+	case err == nil:
+		limit += t.aimd.Increase
+	case t.aimd.IsOverload == nil || t.aimd.IsOverload(err):
+		limit = Limit(float64(limit) * t.aimd.Decrease)
+	default:
+		return
+	}
+	t.limiter.SetLimit(t.clamp(limit))
+}
+
+// clamp keeps the limit between the minimum and maximum of the AIMD.
+func (t *Throttle) clamp(limit Limit) Limit {
+	return min(max(limit, t.aimd.Min), t.aimd.Max)
+}
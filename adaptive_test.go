@@ -0,0 +1,115 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// errOverload simulates an overloaded downstream service.
+var errOverload = errors.New("overload")
+
+// TestThrottleAIMD verifies the adaption of the limit to the task errors.
+func TestThrottleAIMD(t *testing.T) {
+	throttle := wait.NewThrottle(100, 1000, wait.WithAIMD(wait.AIMD{
+		Min:      10,
+		Max:      200,
+		Increase: 10,
+		Decrease: 0.5,
+		IsOverload: func(err error) bool {
+			return errors.Is(err, errOverload)
+		},
+	}))
+	ctx := context.Background()
+	succeed := func() error { return nil }
+	overload := func() error { return errOverload }
+	fail := func() error { return errors.New("invalid") }
+
+	verify.NoError(t, throttle.Process(ctx, succeed))
+	verify.Equal(t, throttle.Limit(), wait.Limit(110))
+
+	verify.ErrorContains(t, throttle.Process(ctx, overload), "overload")
+	verify.Equal(t, throttle.Limit(), wait.Limit(55))
+
+	verify.ErrorContains(t, throttle.Process(ctx, fail), "invalid")
+	verify.Equal(t, throttle.Limit(), wait.Limit(55), "other errors keep the limit")
+
+	for range 5 {
+		throttle.Process(ctx, overload)
+	}
+	verify.Equal(t, throttle.Limit(), wait.Limit(10), "minimum limit")
+
+	for range 50 {
+		throttle.Process(ctx, succeed)
+	}
+	verify.Equal(t, throttle.Limit(), wait.Limit(200), "maximum limit")
+}
+
+// TestThrottleAIMDDefaults verifies the AIMD without classification
+// and maximum.
+func TestThrottleAIMDDefaults(t *testing.T) {
+	throttle := wait.NewThrottle(5, 1000, wait.WithAIMD(wait.AIMD{
+		Min:      10,
+		Increase: 100,
+		Decrease: 0.1,
+	}))
+	ctx := context.Background()
+	verify.Equal(t, throttle.Limit(), wait.Limit(10), "initial limit raised to minimum")
+
+	for range 10 {
+		throttle.Process(ctx, func() error { return nil })
+	}
+	verify.Equal(t, throttle.Limit(), wait.Limit(1010), "no maximum")
+
+	throttle.Process(ctx, func() error { return errors.New("any") })
+	verify.AboutEqual(t, float64(throttle.Limit()), 101.0, 0.0001, "every error is an overload")
+}
+
+// TestThrottleAIMDInvalid verifies the replacement of an invalid minimum
+// and decrease, so that the limit never gets stuck at 0.
+func TestThrottleAIMDInvalid(t *testing.T) {
+	throttle := wait.NewThrottle(8, 1000, wait.WithAIMD(wait.AIMD{
+		Increase: 1,
+	}))
+	ctx := context.Background()
+	overload := func() error { return errOverload }
+
+	throttle.Process(ctx, overload)
+	verify.Equal(t, throttle.Limit(), wait.Limit(4), "default decrease")
+
+	for range 5 {
+		throttle.Process(ctx, overload)
+	}
+	verify.Equal(t, throttle.Limit(), wait.Limit(1), "default minimum")
+
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	verify.Equal(t, throttle.Limit(), wait.Limit(2))
+}
+
+// TestThrottleAIMDRefund verifies that refunded tasks don't change the limit.
+func TestThrottleAIMDRefund(t *testing.T) {
+	throttle := wait.NewThrottle(100, 1000, wait.WithAIMD(wait.AIMD{
+		Min:      10,
+		Increase: 10,
+		Decrease: 0.5,
+	}))
+	ctx := context.Background()
+
+	err := throttle.Process(ctx, func() error {
+		return fmt.Errorf("%w: invalid input", wait.ErrRefund)
+	})
+	verify.True(t, errors.Is(err, wait.ErrRefund))
+	verify.Equal(t, throttle.Limit(), wait.Limit(100))
+}
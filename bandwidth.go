@@ -0,0 +1,109 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"io"
+)
+
+// ThrottledReader limits the bytes per second read from a reader. Reads
+// larger than the burst are cut into burst-sized chunks. It is not safe
+// for concurrent use.
+type ThrottledReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	throttle *Throttle
+}
+
+// NewThrottledReader wraps the reader with a limit in bytes per second and
+// a burst in bytes. Waiting for the limit ends with the context.
+func NewThrottledReader(ctx context.Context, r io.Reader, limit Limit, burst int) *ThrottledReader {
+	return &ThrottledReader{
+		ctx:      ctx,
+		reader:   r,
+		throttle: NewThrottle(limit, burst),
+	}
+}
+
+// Throttle returns the throttle of the reader, e.g. to change the limit.
+func (tr *ThrottledReader) Throttle() *Throttle {
+	return tr.throttle
+}
+
+// Read reads up to a chunk of bytes and waits until the limit allows them.
+func (tr *ThrottledReader) Read(p []byte) (int, error) {
+	if err := tr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	p = p[:chunkSize(tr.throttle, len(p))]
+	n, err := tr.reader.Read(p)
+	if n > 0 {
+		if _, werr := tr.throttle.reserve(tr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// ThrottledWriter limits the bytes per second written to a writer. Writes
+// larger than the burst are cut into burst-sized chunks. It is not safe
+// for concurrent use.
+type ThrottledWriter struct {
+	ctx      context.Context
+	writer   io.Writer
+	throttle *Throttle
+}
+
+// NewThrottledWriter wraps the writer with a limit in bytes per second and
+// a burst in bytes. Waiting for the limit ends with the context.
+func NewThrottledWriter(ctx context.Context, w io.Writer, limit Limit, burst int) *ThrottledWriter {
+	return &ThrottledWriter{
+		ctx:      ctx,
+		writer:   w,
+		throttle: NewThrottle(limit, burst),
+	}
+}
+
+// Throttle returns the throttle of the writer, e.g. to change the limit.
+func (tw *ThrottledWriter) Throttle() *Throttle {
+	return tw.throttle
+}
+
+// Write writes the bytes chunk by chunk, each one when the limit allows it.
+func (tw *ThrottledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		size := chunkSize(tw.throttle, len(p)-written)
+		if _, err := tw.throttle.reserve(tw.ctx, size); err != nil {
+			return written, err
+		}
+		n, err := tw.writer.Write(p[written : written+size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// CopyThrottled copies from src to dst like io.Copy but with a limit in
+// bytes per second and a burst in bytes. It returns the number of copied
+// bytes and the first error, also if the context ends.
+func CopyThrottled(ctx context.Context, dst io.Writer, src io.Reader, limit Limit, burst int) (int64, error) {
+	return io.Copy(NewThrottledWriter(ctx, dst, limit, burst), src)
+}
+
+// chunkSize returns the number of bytes out of size to process at once.
+func chunkSize(t *Throttle, size int) int {
+	burst := t.Burst()
+	if burst < 1 || t.Limit() == InfLimit {
+		return size
+	}
+	return min(size, burst)
+}
@@ -0,0 +1,82 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottledReader verifies the limited reading of bytes.
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3000)
+	r := wait.NewThrottledReader(context.Background(), bytes.NewReader(data), 10000, 1000)
+
+	// Chunks are limited to the burst.
+	p := make([]byte, 2000)
+	n, err := r.Read(p)
+	verify.NoError(t, err)
+	verify.Equal(t, n, 1000)
+
+	// Further 2000 bytes need 200 milliseconds.
+	start := time.Now()
+	read, err := io.ReadAll(r)
+	verify.NoError(t, err)
+	verify.Equal(t, len(read), 2000)
+	verify.DurationAboutEqual(t, time.Since(start), 200*time.Millisecond, 30*time.Millisecond)
+}
+
+// TestThrottledWriter verifies the limited writing of bytes.
+func TestThrottledWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := wait.NewThrottledWriter(context.Background(), &buf, 10000, 500)
+
+	start := time.Now()
+	n, err := w.Write(bytes.Repeat([]byte("x"), 2500))
+	verify.NoError(t, err)
+	verify.Equal(t, n, 2500)
+	verify.Equal(t, buf.Len(), 2500)
+	verify.DurationAboutEqual(t, time.Since(start), 200*time.Millisecond, 30*time.Millisecond)
+
+	// Changing the limit via the throttle.
+	w.Throttle().SetLimit(wait.InfLimit)
+	start = time.Now()
+	n, err = w.Write(bytes.Repeat([]byte("x"), 100000))
+	verify.NoError(t, err)
+	verify.Equal(t, n, 100000)
+	verify.True(t, time.Since(start) < 10*time.Millisecond, "unlimited writing")
+}
+
+// TestCopyThrottled verifies the limited copying and its cancellation.
+func TestCopyThrottled(t *testing.T) {
+	var buf bytes.Buffer
+	src := bytes.NewReader(bytes.Repeat([]byte("x"), 2000))
+
+	start := time.Now()
+	n, err := wait.CopyThrottled(context.Background(), &buf, src, 10000, 1000)
+	verify.NoError(t, err)
+	verify.Equal(t, n, int64(2000))
+	verify.DurationAboutEqual(t, time.Since(start), 100*time.Millisecond, 30*time.Millisecond)
+
+	// The context ends the copying.
+	buf.Reset()
+	src = bytes.NewReader(bytes.Repeat([]byte("x"), 100000))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n, err = wait.CopyThrottled(ctx, &buf, src, 10000, 1000)
+	verify.ErrorContains(t, err, "deadline exceeded")
+	verify.True(t, n < 100000, "copying stopped")
+	verify.Equal(t, int64(buf.Len()), n)
+}
@@ -0,0 +1,191 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TaskError is contained in the joined error returned by ProcessAll() for
+// each task that failed or has never been started.
+type TaskError struct {
+	// Index is the position of the task in the passed tasks.
+	Index int
+
+	// Started tells if the task has been processed.
+	Started bool
+
+	// Err is the error of the task or the reason it hasn't been started.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *TaskError) Error() string {
+	if e.Started {
+		return fmt.Sprintf("task %d failed: %v", e.Index, e.Err)
+	}
+	return fmt.Sprintf("task %d not started: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the task.
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// WithParallelism sets the number of tasks of a batch processed concurrently
+// by ProcessAll(). Values less than 2 mean the tasks are processed one after
+// the other. With a limited number of concurrent tasks it is also limited by
+// the free slots of the throttle.
+func WithParallelism(parallel int) ThrottleOption {
+	return func(t *Throttle) {
+		t.parallel = parallel
+	}
+}
+
+// ProcessAll processes the tasks under the context in batches. Each batch
+// contains as many tasks as the burst allows at once. With a limited number
+// of concurrent tasks each running task of a batch occupies a slot. A batch
+// is admitted with one slot, further tasks of it run concurrently only if
+// other slots are free. The returned error joins a *TaskError for each task
+// that failed or has not been started.
+func (t *Throttle) ProcessAll(ctx context.Context, tasks ...Task) error {
+	var errs []error
+	for first := 0; first < len(tasks); {
+		size := len(tasks) - first
+		if burst := t.limiter.Burst(); burst > 0 && t.limiter.Limit() != InfLimit {
+			size = min(size, burst)
+		}
+		batch := tasks[first : first+size]
+		if err := t.processBatch(ctx, batch, first, &errs); err != nil {
+			for i := first; i < len(tasks); i++ {
+				errs = append(errs, &TaskError{Index: i, Err: err})
+			}
+			break
+		}
+		first += size
+	}
+	return errors.Join(errs...)
+}
+
+// processBatch waits for the admission of a batch of tasks and processes
+// them. The errors of the tasks are appended to errs, the admission error
+// is returned.
+func (t *Throttle) processBatch(ctx context.Context, batch []Task, first int, errs *[]error) (err error) {
+	ctx, leave, err := t.enter(ctx, len(batch))
+	if err != nil {
+		return err
+	}
+	ran := 0
+	defer func() { leave(ran, err) }()
+	if err := t.enqueue(); err != nil {
+		return err
+	}
+	// Reserve the tokens one by one, so that each task can give its token
+	// back.
+	r, err := t.admit(ctx, t.priorityKey(0), batchLimiter{t.limiter}, len(batch))
+	if err != nil {
+		return err
+	}
+	defer t.freeSlot()
+	if ctx.Err() != nil {
+		refund(r, time.Now())
+		return fmt.Errorf("throttle context done before task start: %w", doneErr(ctx))
+	}
+	parts := r.(*batchReservation).parts
+	ran = len(batch)
+	// Process the tasks with the configured parallelism. The admitted slot
+	// is used by one task at a time, further ones need free slots.
+	var wg sync.WaitGroup
+	parallel := make(chan struct{}, max(t.parallel, 1))
+	own := make(chan struct{}, 1)
+	own <- struct{}{}
+	taskErrs := make([]error, len(batch))
+	for i, task := range batch {
+		parallel <- struct{}{}
+		release := t.batchSlot(own)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-parallel }()
+			defer release()
+			_, taskErrs[i] = t.call(costOne(task))
+			if errors.Is(taskErrs[i], ErrRefund) {
+				refund(parts[i], time.Now())
+				return
+			}
+			if t.aimd != nil {
+				t.adapt(taskErrs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	for i, err := range taskErrs {
+		if err != nil {
+			*errs = append(*errs, &TaskError{Index: first + i, Started: true, Err: err})
+		}
+	}
+	return nil
+}
+
+// batchSlot waits until the admitted slot of a batch is free or another
+// slot of the throttle can be taken. It returns the function to free it.
+func (t *Throttle) batchSlot(own chan struct{}) func() {
+	if t.slots == nil {
+		return func() {}
+	}
+	select {
+	case <-own:
+		return func() { own <- struct{}{} }
+	case t.slots <- struct{}{}:
+		return func() { <-t.slots }
+	}
+}
+
+// batchLimiter reserves the tokens of a batch one by one at the same time.
+type batchLimiter struct {
+	Limiter
+}
+
+func (bl batchLimiter) Reserve(now time.Time, n int) Reservation {
+	parts := make([]Reservation, 0, n)
+	at := now
+	for range n {
+		r := bl.Limiter.Reserve(now, 1)
+		if !r.OK() {
+			cancelAll(parts, now)
+			return &reservation{}
+		}
+		parts = append(parts, r)
+		if act := now.Add(r.DelayFrom(now)); act.After(at) {
+			at = act
+		}
+	}
+	return &batchReservation{
+		reservation: reservation{
+			ok: true,
+			at: at,
+			cancel: func(now time.Time) {
+				for _, r := range parts {
+					refund(r, now)
+				}
+			},
+		},
+		parts: parts,
+	}
+}
+
+// batchReservation contains the reservations of the single tasks of a
+// batch, so that they can be given back on their own.
+type batchReservation struct {
+	reservation
+	parts []Reservation
+}
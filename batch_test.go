@@ -0,0 +1,139 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottleProcessAll verifies the processing of tasks in batches of
+// the burst size.
+func TestThrottleProcessAll(t *testing.T) {
+	throttle := wait.NewThrottle(10, 5)
+	ctx := context.Background()
+	start := time.Now()
+	durations := make([]time.Duration, 12)
+	tasks := make([]wait.Task, len(durations))
+	for i := range tasks {
+		tasks[i] = func() error {
+			durations[i] = time.Since(start)
+			return nil
+		}
+	}
+
+	verify.NoError(t, throttle.ProcessAll(ctx, tasks...))
+	for i, expected := range []time.Duration{0, 500 * time.Millisecond, 700 * time.Millisecond} {
+		first, last := i*5, min(i*5+5, len(tasks))
+		for _, d := range durations[first:last] {
+			verify.DurationAboutEqual(t, d, expected, 30*time.Millisecond)
+		}
+	}
+}
+
+// TestThrottleProcessAllErrors verifies the joined error of failed and
+// not started tasks.
+func TestThrottleProcessAllErrors(t *testing.T) {
+	throttle := wait.NewThrottle(10, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ouch := errors.New("ouch")
+	started := 0
+	tasks := make([]wait.Task, 6)
+	for i := range tasks {
+		tasks[i] = func() error {
+			started++
+			if i == 1 {
+				return ouch
+			}
+			return nil
+		}
+	}
+
+	err := throttle.ProcessAll(ctx, tasks...)
+	verify.Equal(t, started, 2)
+	verify.True(t, errors.Is(err, ouch))
+	verify.True(t, errors.Is(err, context.DeadlineExceeded))
+	joined, ok := err.(interface{ Unwrap() []error })
+	verify.True(t, ok, "joined error")
+	errs := joined.Unwrap()
+	verify.Length(t, errs, 5)
+	for i, err := range errs {
+		var terr *wait.TaskError
+		verify.True(t, errors.As(err, &terr))
+		verify.Equal(t, terr.Index, i+1)
+		verify.Equal(t, terr.Started, i == 0)
+	}
+	verify.ErrorContains(t, errs[0], "task 1 failed: ouch")
+	verify.ErrorContains(t, errs[1], "task 2 not started")
+}
+
+// TestThrottleProcessAllParallelism verifies the concurrent processing of
+// the tasks of a batch.
+func TestThrottleProcessAllParallelism(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 0, wait.WithParallelism(3))
+	counter := &concurrencyCounter{}
+	tasks := make([]wait.Task, 9)
+	for i := range tasks {
+		tasks[i] = func() error {
+			counter.incr()
+			defer counter.decr()
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}
+	}
+
+	verify.NoError(t, throttle.ProcessAll(context.Background(), tasks...))
+	verify.Equal(t, counter.max(), 3)
+
+	// The parallelism is limited by the free slots.
+	throttle = wait.NewThrottle(wait.InfLimit, 0, wait.WithParallelism(5), wait.WithMaxInFlight(2))
+	counter = &concurrencyCounter{}
+	verify.NoError(t, throttle.ProcessAll(context.Background(), tasks...))
+	verify.Equal(t, counter.max(), 2)
+	verify.Equal(t, throttle.InFlight(), 0)
+
+	throttle = wait.NewThrottle(wait.InfLimit, 0, wait.WithParallelism(5), wait.WithMaxInFlight(1))
+	counter = &concurrencyCounter{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for range 2 {
+		go func() {
+			defer wg.Done()
+			verify.NoError(t, throttle.ProcessAll(context.Background(), tasks...))
+		}()
+	}
+	wg.Wait()
+	verify.Equal(t, counter.max(), 1)
+}
+
+// TestThrottleProcessAllRefund verifies the giving back of the tokens of
+// single tasks of a batch.
+func TestThrottleProcessAllRefund(t *testing.T) {
+	throttle := wait.NewThrottle(1, 2)
+	ctx := context.Background()
+	invalid := func() error {
+		return fmt.Errorf("%w: invalid input", wait.ErrRefund)
+	}
+
+	err := throttle.ProcessAll(ctx, invalid, invalid)
+	verify.True(t, errors.Is(err, wait.ErrRefund))
+	verify.AboutEqual(t, throttle.Tokens(), 2.0, 0.01)
+
+	err = throttle.ProcessAll(ctx, invalid, func() error { return nil })
+	verify.True(t, errors.Is(err, wait.ErrRefund))
+	verify.AboutEqual(t, throttle.Tokens(), 1.0, 0.01)
+}
@@ -0,0 +1,68 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"sync"
+)
+
+// ThrottleChan passes the values of the input channel with the limit and
+// burst per second to the returned output channel. The output is closed
+// when the input is closed or the context ends.
+func ThrottleChan[T any](ctx context.Context, in <-chan T, limit Limit, burst int) <-chan T {
+	return ThrottleMap(ctx, in, limit, burst, 1, func(v T) T {
+		return v
+	})
+}
+
+// ThrottleMap applies the function to the values of the input channel with
+// the limit and burst per second and passes the results to the returned
+// output channel. Up to parallel values are processed concurrently, so the
+// order of the results may differ from the input. The output is closed when
+// the input is closed and all values are processed or the context ends.
+func ThrottleMap[T, R any](ctx context.Context, in <-chan T, limit Limit, burst, parallel int, fn func(v T) R) <-chan R {
+	out := make(chan R)
+	throttle := NewThrottle(limit, burst)
+	var wg sync.WaitGroup
+	for range max(parallel, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var v T
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case v, ok = <-in:
+					if !ok {
+						return
+					}
+				}
+				var r R
+				if err := throttle.Process(ctx, func() error {
+					r = fn(v)
+					return nil
+				}); err != nil {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- r:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
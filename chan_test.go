@@ -0,0 +1,87 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottleChan verifies the limited passing of values.
+func TestThrottleChan(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := range 5 {
+			in <- i
+		}
+	}()
+
+	start := time.Now()
+	var values []int
+	for v := range wait.ThrottleChan(context.Background(), in, 20, 1) {
+		values = append(values, v)
+	}
+	verify.True(t, slices.Equal(values, []int{0, 1, 2, 3, 4}), "values in order")
+	verify.DurationAboutEqual(t, time.Since(start), 200*time.Millisecond, 30*time.Millisecond)
+}
+
+// TestThrottleMap verifies the limited and parallel mapping of values.
+func TestThrottleMap(t *testing.T) {
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := range 10 {
+			in <- i
+		}
+	}()
+
+	counter := &concurrencyCounter{}
+	square := func(v int) int {
+		counter.incr()
+		defer counter.decr()
+		time.Sleep(20 * time.Millisecond)
+		return v * v
+	}
+	var values []int
+	for v := range wait.ThrottleMap(context.Background(), in, wait.InfLimit, 0, 3, square) {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	verify.True(t, slices.Equal(values, []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}), "all values mapped")
+	verify.Equal(t, counter.max(), 3)
+}
+
+// TestThrottleChanCancel verifies the closing of the output when the
+// context ends without leaking goroutines.
+func TestThrottleChanCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	out := wait.ThrottleMap(ctx, in, 10, 1, 4, func(v int) int { return v })
+
+	in <- 1
+	verify.Equal(t, <-out, 1)
+	in <- 2
+	cancel()
+	for range out {
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	verify.True(t, runtime.NumGoroutine() <= before, "no leaked goroutines")
+}
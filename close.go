@@ -0,0 +1,122 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrClosed is returned for tasks passed to a closed Throttle or waiting
+// while it has been closed.
+var ErrClosed = errors.New("throttle closed")
+
+// ShutdownReport tells how many tasks have been drained, so processed after
+// closing the throttle, and how many have been rejected with ErrClosed. Tasks
+// rejected for other reasons, e.g. by WithMaxQueue() or an ending context,
+// are not contained.
+type ShutdownReport struct {
+	Drained  int
+	Rejected int
+}
+
+// Close closes the throttle. New tasks are rejected with ErrClosed as well as
+// all waiting tasks. Tasks already admitted are still processed, but Close
+// doesn't wait for them.
+func (t *Throttle) Close() error {
+	t.lifeMu.Lock()
+	defer t.lifeMu.Unlock()
+
+	t.close()
+	return nil
+}
+
+// Shutdown closes the throttle like Close and waits until the already
+// admitted tasks are processed. It returns the report of drained and
+// rejected tasks. If the context ends before all tasks are processed the
+// report so far and the error of the context are returned.
+func (t *Throttle) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	t.lifeMu.Lock()
+	t.close()
+	t.lifeMu.Unlock()
+
+	var err error
+	select {
+	case <-t.idle:
+	case <-ctx.Done():
+		err = fmt.Errorf("wait for throttle shutdown: %w", ctx.Err())
+	}
+
+	t.lifeMu.Lock()
+	defer t.lifeMu.Unlock()
+
+	return ShutdownReport{
+		Drained:  t.drained,
+		Rejected: t.rejected,
+	}, err
+}
+
+// close cancels the closing context and signals idleness if no tasks are
+// active. The mutex has to be locked.
+func (t *Throttle) close() {
+	if t.closing.Err() != nil {
+		return
+	}
+	t.closeFn()
+	if t.active == 0 {
+		close(t.idle)
+	}
+}
+
+// enter registers n tasks entering the throttle. The returned context ends
+// with ErrClosed as cause if the throttle is closed. The returned function
+// has to be called with the number of processed tasks and the error when
+// leaving.
+func (t *Throttle) enter(ctx context.Context, n int) (context.Context, func(ran int, err error), error) {
+	t.lifeMu.Lock()
+	defer t.lifeMu.Unlock()
+
+	if t.closing.Err() != nil {
+		t.rejected += n
+		return nil, nil, fmt.Errorf("process task: %w", ErrClosed)
+	}
+	t.active += n
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(t.closing, func() {
+		cancel(ErrClosed)
+	})
+	leave := func(ran int, err error) {
+		stop()
+		cancel(nil)
+		t.lifeMu.Lock()
+		defer t.lifeMu.Unlock()
+
+		t.active -= n
+		if t.closing.Err() == nil {
+			return
+		}
+		t.drained += ran
+		if ran < n && errors.Is(err, ErrClosed) {
+			t.rejected += n - ran
+		}
+		if t.active == 0 {
+			close(t.idle)
+		}
+	}
+	return ctx, leave, nil
+}
+
+// doneErr returns the reason for the end of a context, ErrClosed if it
+// ended by closing the throttle.
+func doneErr(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrClosed) {
+		return cause
+	}
+	return ctx.Err()
+}
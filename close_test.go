@@ -0,0 +1,152 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottleClose verifies the rejection of tasks by a closed throttle.
+func TestThrottleClose(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 0)
+	ctx := context.Background()
+
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	verify.NoError(t, throttle.Close())
+	verify.NoError(t, throttle.Close())
+
+	err := throttle.Process(ctx, func() error { return nil })
+	verify.True(t, errors.Is(err, wait.ErrClosed))
+	err = throttle.ProcessAll(ctx, func() error { return nil })
+	verify.True(t, errors.Is(err, wait.ErrClosed))
+	err = throttle.Submit(ctx, func() error { return nil }).Wait(ctx)
+	verify.True(t, errors.Is(err, wait.ErrClosed))
+}
+
+// TestThrottleShutdown verifies the draining of admitted tasks and the
+// rejection of waiting ones.
+func TestThrottleShutdown(t *testing.T) {
+	throttle := wait.NewThrottle(1, 1)
+	ctx := context.Background()
+	started := make(chan struct{})
+	errs := make(chan error, 4)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs <- throttle.Process(ctx, func() error {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		})
+	}()
+	<-started
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- throttle.Process(ctx, func() error { return nil })
+		}()
+	}
+	err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+		return throttle.Waiting() == 3, nil
+	})
+	verify.NoError(t, err)
+
+	start := time.Now()
+	report, err := throttle.Shutdown(ctx)
+	verify.NoError(t, err)
+	verify.DurationAboutEqual(t, time.Since(start), 100*time.Millisecond, 30*time.Millisecond)
+	verify.Equal(t, report, wait.ShutdownReport{Drained: 1, Rejected: 3})
+
+	wg.Wait()
+	close(errs)
+	closed := 0
+	for err := range errs {
+		if errors.Is(err, wait.ErrClosed) {
+			closed++
+			continue
+		}
+		verify.NoError(t, err)
+	}
+	verify.Equal(t, closed, 3)
+}
+
+// TestThrottleShutdownTimeout verifies the end of waiting for the draining
+// with the context.
+func TestThrottleShutdownTimeout(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 0, wait.WithWorkers(2))
+	ctx := context.Background()
+	started := make(chan struct{})
+
+	f := throttle.Submit(ctx, func() error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	<-started
+
+	sctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	report, err := throttle.Shutdown(sctx)
+	verify.True(t, errors.Is(err, context.DeadlineExceeded))
+	verify.Equal(t, report, wait.ShutdownReport{})
+
+	verify.NoError(t, f.Wait(ctx))
+	report, err = throttle.Shutdown(ctx)
+	verify.NoError(t, err)
+	verify.Equal(t, report, wait.ShutdownReport{Drained: 1})
+}
+
+// TestThrottleShutdownReport verifies that only processed tasks are counted
+// as drained, even if they return ErrClosed themselves.
+func TestThrottleShutdownReport(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 0)
+	downstream := wait.NewThrottle(wait.InfLimit, 0)
+	verify.NoError(t, downstream.Close())
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- throttle.Process(ctx, func() error {
+			close(started)
+			<-release
+			return downstream.Process(ctx, func() error { return nil })
+		})
+	}()
+	<-started
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	report, err := throttle.Shutdown(ctx)
+	verify.NoError(t, err)
+	verify.Equal(t, report, wait.ShutdownReport{Drained: 1})
+	verify.True(t, errors.Is(<-errs, wait.ErrClosed))
+
+	// Tasks not processed because of their context are not counted.
+	throttle = wait.NewThrottle(1, 1)
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	verify.True(t, errors.Is(throttle.Process(tctx, func() error { return nil }), context.DeadlineExceeded))
+	report, err = throttle.Shutdown(ctx)
+	verify.NoError(t, err)
+	verify.Equal(t, report, wait.ShutdownReport{})
+}
@@ -0,0 +1,224 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ConcurrencyAlgorithm calculates the limit of concurrently processed tasks
+// of a ConcurrencyLimiter out of the measured latencies. Implementations
+// don't need to be safe for concurrent use, the limiter serializes the
+// calls.
+type ConcurrencyAlgorithm interface {
+	// Update takes the latency of a processed task, the number of tasks
+	// in flight when it started, and if it has been dropped. It returns
+	// the new limit.
+	Update(latency time.Duration, inFlight int, dropped bool) int
+
+	// Limit returns the current limit.
+	Limit() int
+}
+
+// NewVegas returns a ConcurrencyAlgorithm like TCP Vegas. It estimates the
+// queue size out of the relation between the minimal and the current latency.
+// A small queue raises the limit, a large one or a dropped task lowers it.
+func NewVegas(initial, min, max int) ConcurrencyAlgorithm {
+	return &vegas{
+		limit: float64(initial),
+		min:   float64(min),
+		max:   float64(max),
+	}
+}
+
+// vegas implements the Vegas algorithm.
+type vegas struct {
+	limit  float64
+	min    float64
+	max    float64
+	noLoad time.Duration
+}
+
+func (v *vegas) Update(latency time.Duration, inFlight int, dropped bool) int {
+	if v.noLoad == 0 || latency < v.noLoad {
+		v.noLoad = latency
+		return v.Limit()
+	}
+	log := max(1, math.Log10(v.limit))
+	switch {
+	case dropped:
+		v.limit -= log
+	case float64(inFlight)*2 < v.limit:
+		// Too few tasks to say anything about the limit.
+		return v.Limit()
+	default:
+		queue := math.Ceil(v.limit * (1 - float64(v.noLoad)/float64(latency)))
+		alpha := 3 * log
+		beta := 6 * log
+		switch {
+		case queue <= log:
+			v.limit += beta
+		case queue < alpha:
+			v.limit += log
+		case queue > beta:
+			v.limit -= log
+		}
+	}
+	v.limit = min(max(v.limit, v.min), v.max)
+	return v.Limit()
+}
+
+func (v *vegas) Limit() int {
+	return int(v.limit)
+}
+
+// NewGradient2 returns a ConcurrencyAlgorithm like Gradient2. It compares
+// the current latency with a long-term exponential average. A growing latency
+// lowers the limit, a stable one lets it grow by the square root of the limit.
+// Dropped tasks are not taken into account.
+func NewGradient2(initial, min, max int) ConcurrencyAlgorithm {
+	return &gradient2{
+		limit:     float64(initial),
+		min:       float64(min),
+		max:       float64(max),
+		window:    600,
+		tolerance: 1.5,
+		smoothing: 0.2,
+	}
+}
+
+// gradient2 implements the Gradient2 algorithm.
+type gradient2 struct {
+	limit     float64
+	min       float64
+	max       float64
+	window    float64
+	tolerance float64
+	smoothing float64
+	long      float64
+}
+
+func (g *gradient2) Update(latency time.Duration, inFlight int, _ bool) int {
+	short := float64(latency)
+	if g.long == 0 {
+		g.long = short
+	} else {
+		g.long = g.long*(1-1/g.window) + short/g.window
+	}
+	// Let the long-term average recover faster after an overload.
+	if g.long/short > 2 {
+		g.long *= 0.95
+	}
+	if float64(inFlight) < g.limit/2 {
+		// Too few tasks to say anything about the limit.
+		return g.Limit()
+	}
+	gradient := max(0.5, min(1.0, g.tolerance*g.long/short))
+	limit := g.limit*gradient + math.Sqrt(g.limit)
+	limit = g.limit*(1-g.smoothing) + limit*g.smoothing
+	g.limit = min(max(limit, g.min), g.max)
+	return g.Limit()
+}
+
+func (g *gradient2) Limit() int {
+	return int(g.limit)
+}
+
+// ConcurrencyLimiter limits the number of concurrently processed tasks.
+// Other than with a fixed maximum the limit is discovered by measuring the
+// latency of the tasks with a ConcurrencyAlgorithm.
+type ConcurrencyLimiter struct {
+	mu        sync.Mutex
+	algorithm ConcurrencyAlgorithm
+	isDrop    func(err error) bool
+	inFlight  int
+	changed   chan struct{}
+}
+
+// NewConcurrencyLimiter creates a limiter using the given algorithm. The
+// function isDrop classifies the errors of the tasks, those returning true
+// are passed as dropped to the algorithm. If it is nil every error is a drop.
+func NewConcurrencyLimiter(algorithm ConcurrencyAlgorithm, isDrop func(err error) bool) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		algorithm: algorithm,
+		isDrop:    isDrop,
+		changed:   make(chan struct{}),
+	}
+}
+
+// Process processes a task under the context, waiting for a free slot
+// if necessary. Its latency is used to update the limit. A panicking task
+// frees its slot and counts as dropped before the panic is passed on.
+func (cl *ConcurrencyLimiter) Process(ctx context.Context, task Task) error {
+	inFlight, err := cl.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	dropped := true
+	defer func() {
+		cl.release(time.Since(start), inFlight, dropped)
+	}()
+	err = task()
+	dropped = err != nil && (cl.isDrop == nil || cl.isDrop(err))
+	return err
+}
+
+// Limit returns the current limit of concurrently processed tasks.
+func (cl *ConcurrencyLimiter) Limit() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return cl.algorithm.Limit()
+}
+
+// InFlight returns the number of tasks currently processed.
+func (cl *ConcurrencyLimiter) InFlight() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return cl.inFlight
+}
+
+
+// acquire waits for a free slot and returns the number of tasks in
+// flight including the new one.
+func (cl *ConcurrencyLimiter) acquire(ctx context.Context) (int, error) {
+	for {
+		cl.mu.Lock()
+		if cl.inFlight < max(cl.algorithm.Limit(), 1) {
+			cl.inFlight++
+			inFlight := cl.inFlight
+			cl.mu.Unlock()
+			return inFlight, nil
+		}
+		changed := cl.changed
+		cl.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, fmt.Errorf("wait for concurrency slot: %w", ctx.Err())
+		}
+	}
+}
+
+// release frees the slot, updates the algorithm, and wakes up the waiting
+// tasks.
+func (cl *ConcurrencyLimiter) release(latency time.Duration, inFlight int, dropped bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.inFlight--
+	cl.algorithm.Update(latency, inFlight, dropped)
+	close(cl.changed)
+	cl.changed = make(chan struct{})
+}
@@ -0,0 +1,159 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestConcurrencyAlgorithms verifies the algorithms with synthetic
+// latency distributions.
+func TestConcurrencyAlgorithms(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm func() wait.ConcurrencyAlgorithm
+	}{
+		{
+			name:      "vegas",
+			algorithm: func() wait.ConcurrencyAlgorithm { return wait.NewVegas(10, 5, 200) },
+		}, {
+			name:      "gradient2",
+			algorithm: func() wait.ConcurrencyAlgorithm { return wait.NewGradient2(10, 5, 200) },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			algorithm := test.algorithm()
+			rnd := rand.New(rand.NewPCG(1, 2))
+			// latency returns a normal distributed latency around the mean.
+			latency := func(mean time.Duration) time.Duration {
+				return mean + time.Duration(rnd.NormFloat64()*float64(mean)/20)
+			}
+
+			// Stable latency under full load lets the limit grow.
+			for range 200 {
+				algorithm.Update(latency(10*time.Millisecond), algorithm.Limit(), false)
+			}
+			grown := algorithm.Limit()
+			verify.More(t, grown, 10)
+
+			// Few tasks in flight don't change the limit.
+			for range 200 {
+				algorithm.Update(latency(10*time.Millisecond), 1, false)
+			}
+			verify.Equal(t, algorithm.Limit(), grown)
+
+			// Rising latency signals queueing and lowers the limit.
+			for range 200 {
+				algorithm.Update(latency(50*time.Millisecond), algorithm.Limit(), false)
+			}
+			shrunk := algorithm.Limit()
+			verify.Less(t, shrunk, grown)
+			verify.InRange(t, shrunk, 5, 200)
+		})
+	}
+}
+
+// TestVegasDrops verifies the lowering of the limit by dropped tasks.
+func TestVegasDrops(t *testing.T) {
+	algorithm := wait.NewVegas(100, 10, 200)
+	algorithm.Update(10*time.Millisecond, 100, false)
+	for range 10 {
+		algorithm.Update(10*time.Millisecond, 100, true)
+	}
+	verify.Equal(t, algorithm.Limit(), 80)
+}
+
+// TestConcurrencyLimiter verifies the limitation of concurrently
+// processed tasks.
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := wait.NewConcurrencyLimiter(wait.NewVegas(3, 3, 3), nil)
+	ctx := context.Background()
+	cc := &concurrencyCounter{}
+	task := func() error {
+		cc.incr()
+		defer cc.decr()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	var wg sync.WaitGroup
+	wg.Add(20)
+	for range 20 {
+		go func() {
+			defer wg.Done()
+			verify.NoError(t, limiter.Process(ctx, task))
+		}()
+	}
+	wg.Wait()
+	verify.Equal(t, cc.max(), 3)
+	verify.Equal(t, limiter.Limit(), 3)
+	verify.Equal(t, limiter.InFlight(), 0)
+
+	// Waiting for a slot honours the context.
+	release := make(chan struct{})
+	for range 3 {
+		go limiter.Process(ctx, func() error {
+			<-release
+			return nil
+		})
+	}
+	err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+		return limiter.InFlight() == 3, nil
+	})
+	verify.NoError(t, err)
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err = limiter.Process(tctx, task)
+	verify.ErrorContains(t, err, "wait for concurrency slot")
+	close(release)
+}
+
+// TestConcurrencyLimiterPanic verifies that a panicking task frees its
+// slot and counts as dropped.
+func TestConcurrencyLimiterPanic(t *testing.T) {
+	algorithm := &droppedCounter{}
+	limiter := wait.NewConcurrencyLimiter(algorithm, nil)
+	ctx := context.Background()
+
+	func() {
+		defer func() {
+			verify.Equal(t, recover(), "ouch")
+		}()
+		limiter.Process(ctx, func() error { panic("ouch") })
+	}()
+	verify.Equal(t, limiter.InFlight(), 0)
+	verify.Equal(t, algorithm.dropped, 1)
+
+	verify.NoError(t, limiter.Process(ctx, func() error { return nil }))
+	verify.Equal(t, algorithm.dropped, 1)
+}
+
+// droppedCounter is a ConcurrencyAlgorithm with a fixed limit of 1
+// counting the dropped tasks.
+type droppedCounter struct {
+	dropped int
+}
+
+func (dc *droppedCounter) Update(latency time.Duration, inFlight int, dropped bool) int {
+	if dropped {
+		dc.dropped++
+	}
+	return 1
+}
+
+func (dc *droppedCounter) Limit() int {
+	return 1
+}
@@ -0,0 +1,120 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"sync"
+	"time"
+)
+
+// NewStoreLimiter returns a token bucket Limiter keeping its state in the
+// store with the given key. So throttles in multiple processes or on
+// multiple hosts using the same store and key share one budget. All of them
+// have to use the same limit and burst. Errors of the store reject the
+// tasks of a Throttle with the error.
+func NewStoreLimiter(store Store, key string, limit Limit, burst int) Limiter {
+	return &storeLimiter{
+		store: store,
+		key:   key,
+		limit: limit,
+		burst: burst,
+	}
+}
+
+// NewDistributedThrottle creates a new Throttle with the specified limit and
+// burst sharing its budget via the store.
+func NewDistributedThrottle(store Store, key string, limit Limit, burst int, options ...ThrottleOption) *Throttle {
+	return NewThrottleWithLimiter(NewStoreLimiter(store, key, limit, burst), options...)
+}
+
+// storeLimiter implements a token bucket in a store.
+type storeLimiter struct {
+	mu    sync.Mutex
+	store Store
+	key   string
+	limit Limit
+	burst int
+}
+
+func (sl *storeLimiter) Reserve(now time.Time, n int) Reservation {
+	return sl.reserveFrom(now, now, n)
+}
+
+// reserveFrom takes the tokens at now even if they are used later, like
+// the token bucket of a single process.
+func (sl *storeLimiter) reserveFrom(now, from time.Time, n int) Reservation {
+	limit, burst := sl.Limit(), sl.Burst()
+	if limit == InfLimit {
+		return &reservation{ok: true, at: from}
+	}
+	if n > burst {
+		return &reservation{}
+	}
+	tokens, err := sl.store.Take(sl.key, now, float64(n), limit, burst)
+	if err != nil {
+		return &reservation{err: err}
+	}
+	at := now
+	if tokens < 0 {
+		if limit <= 0 {
+			sl.store.Refill(sl.key, now, float64(n), limit, burst)
+			return &reservation{}
+		}
+		at = now.Add(time.Duration(-tokens / float64(limit) * float64(time.Second)))
+	}
+	if from.After(at) {
+		at = from
+	}
+	return &reservation{
+		ok: true,
+		at: at,
+		cancel: func(now time.Time) {
+			sl.store.Refill(sl.key, now, float64(n), limit, burst)
+		},
+	}
+}
+
+func (sl *storeLimiter) TokensAt(now time.Time) float64 {
+	limit, burst := sl.Limit(), sl.Burst()
+	if limit == InfLimit {
+		return float64(burst)
+	}
+	tokens, err := sl.store.Take(sl.key, now, 0, limit, burst)
+	if err != nil {
+		return 0
+	}
+	return tokens
+}
+
+func (sl *storeLimiter) Limit() Limit {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	return sl.limit
+}
+
+func (sl *storeLimiter) SetLimit(limit Limit) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.limit = limit
+}
+
+func (sl *storeLimiter) Burst() int {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	return sl.burst
+}
+
+func (sl *storeLimiter) SetBurst(burst int) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.burst = burst
+}
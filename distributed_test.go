@@ -0,0 +1,103 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestDistributedThrottle verifies throttles sharing their budget via a
+// store.
+func TestDistributedThrottle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buckets.json")
+	tests := []struct {
+		name   string
+		stores [2]wait.Store
+	}{
+		{
+			name:   "memory",
+			stores: [2]wait.Store{wait.NewMemoryStore(), nil},
+		}, {
+			// Two file stores simulate two processes.
+			name:   "file",
+			stores: [2]wait.Store{wait.NewFileStore(path), wait.NewFileStore(path)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.stores[1] == nil {
+				test.stores[1] = test.stores[0]
+			}
+			throttles := []*wait.Throttle{
+				wait.NewDistributedThrottle(test.stores[0], "api", 20, 2),
+				wait.NewDistributedThrottle(test.stores[1], "api", 20, 2),
+			}
+			ctx := context.Background()
+			start := time.Now()
+			var wg sync.WaitGroup
+			for _, throttle := range throttles {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 3 {
+						verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+					}
+				}()
+			}
+			wg.Wait()
+			// 6 tasks with a burst of 2 need 4 further tokens.
+			verify.DurationAboutEqual(t, time.Since(start), 200*time.Millisecond, 30*time.Millisecond)
+		})
+	}
+}
+
+// TestDistributedThrottleRefund verifies the giving back of tokens to
+// the store.
+func TestDistributedThrottleRefund(t *testing.T) {
+	store := wait.NewMemoryStore()
+	throttle := wait.NewDistributedThrottle(store, "api", 1, 2)
+	ctx := context.Background()
+
+	err := throttle.Process(ctx, func() error {
+		return fmt.Errorf("%w: invalid input", wait.ErrRefund)
+	})
+	verify.True(t, errors.Is(err, wait.ErrRefund))
+	verify.AboutEqual(t, throttle.Tokens(), 2.0, 0.01)
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	verify.AboutEqual(t, throttle.Tokens(), 1.0, 0.01)
+}
+
+// TestDistributedThrottleStoreError verifies the rejection of tasks if
+// the store fails.
+func TestDistributedThrottleStoreError(t *testing.T) {
+	throttle := wait.NewDistributedThrottle(failingStore{}, "api", 1, 2)
+
+	err := throttle.Process(context.Background(), func() error { return nil })
+	verify.ErrorContains(t, err, "store unavailable")
+}
+
+// failingStore is a store always failing.
+type failingStore struct{}
+
+func (failingStore) Take(key string, now time.Time, n float64, limit wait.Limit, burst int) (float64, error) {
+	return 0, errors.New("store unavailable")
+}
+
+func (failingStore) Refill(key string, now time.Time, n float64, limit wait.Limit, burst int) error {
+	return errors.New("store unavailable")
+}
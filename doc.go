@@ -9,7 +9,13 @@
 // standard tickers for the polling are already pre-defined.
 //
 // Additionally the package provide a throttle for the limited processing
-// of events per second.
+// of events per second. The algorithm of the throttle is a token bucket by
+// default, fixed window, sliding window log, sliding window counter, and
+// leaky bucket can be chosen as Limiter too. A token bucket in a Store lets
+// multiple processes share one budget.
+//
+// The throttled reader and writer limit the bandwidth in bytes per second,
+// the throttled listener the rate and number of accepted connections.
 
 package wait
 
@@ -0,0 +1,128 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"sync"
+)
+
+// FairThrottle shares the budget of one Throttle fairly between tenants. Each
+// tenant gets a share of the tasks per second relative to its weight as long
+// as it has tasks waiting, so a single noisy tenant cannot consume the whole
+// budget. Tenants without a configured weight have the weight 1.
+type FairThrottle struct {
+	throttle *Throttle
+	mu       sync.Mutex
+	weights  map[string]float64
+	tenants  map[string]*tenant
+	virtual  float64
+}
+
+// tenant contains the state of an active tenant of a FairThrottle.
+type tenant struct {
+	finish  float64
+	pending int
+}
+
+// NewFairThrottle creates a new FairThrottle with the specified limit and
+// burst as global budget. The options are those of the Throttle.
+func NewFairThrottle(limit Limit, burst int, options ...ThrottleOption) *FairThrottle {
+	throttle := NewThrottle(limit, burst, options...)
+	throttle.gate = &gate{}
+	return &FairThrottle{
+		throttle: throttle,
+		weights:  make(map[string]float64),
+		tenants:  make(map[string]*tenant),
+	}
+}
+
+// Throttle returns the throttle containing the global budget, e.g. to
+// change the limit.
+func (f *FairThrottle) Throttle() *Throttle {
+	return f.throttle
+}
+
+// SetWeight sets the weight of a tenant. A weight less than or equal to 0
+// resets it to 1.
+func (f *FairThrottle) SetWeight(tenant string, weight float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if weight <= 0 {
+		delete(f.weights, tenant)
+		return
+	}
+	f.weights[tenant] = weight
+}
+
+// Weight returns the weight of a tenant.
+func (f *FairThrottle) Weight(tenant string) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.weight(tenant)
+}
+
+// Process processes a task of a tenant under the context, waiting if
+// necessary. Waiting tasks are admitted by start-time fair queueing.
+func (f *FairThrottle) Process(ctx context.Context, tenant string, task Task) error {
+	start, finish := f.enqueue(tenant)
+	defer f.dequeue(tenant)
+	return f.throttle.process(ctx, finish, costOne(func() error {
+		f.serve(start)
+		return task()
+	}))
+}
+
+
+// weight returns the weight of a tenant. The mutex has to be locked.
+func (f *FairThrottle) weight(tenant string) float64 {
+	if weight, ok := f.weights[tenant]; ok {
+		return weight
+	}
+	return 1
+}
+
+// enqueue calculates the start and finish tag of a new task of the tenant.
+func (f *FairThrottle) enqueue(name string) (start, finish float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tenants[name]
+	if !ok {
+		t = &tenant{}
+		f.tenants[name] = t
+	}
+	start = max(f.virtual, t.finish)
+	finish = start + 1/f.weight(name)
+	t.finish = finish
+	t.pending++
+	return start, finish
+}
+
+// serve advances the virtual time to the start tag of the task in service.
+func (f *FairThrottle) serve(start float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.virtual = max(f.virtual, start)
+}
+
+// dequeue removes a processed or cancelled task of the tenant. Tenants
+// without pending tasks are forgotten.
+func (f *FairThrottle) dequeue(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := f.tenants[name]
+	t.pending--
+	if t.pending == 0 {
+		delete(f.tenants, name)
+	}
+}
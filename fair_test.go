@@ -0,0 +1,143 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestFairThrottle verifies the fair sharing of a throttle between tenants.
+func TestFairThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]float64
+		tasks   map[string]int
+		first   int
+		shares  map[string]int
+	}{
+		{
+			name:   "noisy tenant does not starve quiet one",
+			tasks:  map[string]int{"n": 20, "q": 5},
+			first:  10,
+			shares: map[string]int{"n": 5, "q": 5},
+		},
+		{
+			name:    "weights define the shares",
+			weights: map[string]float64{"g": 2},
+			tasks:   map[string]int{"g": 12, "b": 12},
+			first:   9,
+			shares:  map[string]int{"g": 6, "b": 3},
+		},
+	}
+	for _, test := range tests {
+		t.Logf("test: %s", test.name)
+		// Only one task at a time, so all others are queued while a
+		// blocking one runs.
+		fair := wait.NewFairThrottle(wait.InfLimit, 1, wait.WithMaxInFlight(1))
+		for tenant, weight := range test.weights {
+			fair.SetWeight(tenant, weight)
+		}
+		ctx := context.Background()
+		var mu sync.Mutex
+		var order strings.Builder
+		release := make(chan struct{})
+		go fair.Process(ctx, "blocker", func() error {
+			<-release
+			return nil
+		})
+		err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+			return fair.Throttle().InFlight() == 1, nil
+		})
+		verify.NoError(t, err)
+
+		var wg sync.WaitGroup
+		enqueued := 0
+		for _, tenant := range []string{"n", "q", "g", "b"} {
+			for range test.tasks[tenant] {
+				wg.Add(1)
+				enqueued++
+				go func() {
+					defer wg.Done()
+					err := fair.Process(ctx, tenant, func() error {
+						mu.Lock()
+						defer mu.Unlock()
+						order.WriteString(tenant)
+						return nil
+					})
+					verify.NoError(t, err)
+				}()
+				err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+					return fair.Throttle().Waiting() == enqueued, nil
+				})
+				verify.NoError(t, err)
+			}
+		}
+		close(release)
+		wg.Wait()
+
+		t.Logf("order: %s", order.String())
+		first := order.String()[:test.first]
+		for tenant, share := range test.shares {
+			verify.Equal(t, strings.Count(first, tenant), share, "share of tenant "+tenant)
+		}
+	}
+}
+
+// TestFairThrottleTokenBudget verifies the sharing of a limited token
+// budget between tenants without a limit of concurrent tasks.
+func TestFairThrottleTokenBudget(t *testing.T) {
+	fair := wait.NewFairThrottle(2, 1)
+	fair.SetWeight("q", 2)
+	ctx := context.Background()
+	var mu sync.Mutex
+	var order strings.Builder
+
+	// Use the token, so that the first queued task waits for half a second
+	// while all others are enqueued.
+	verify.NoError(t, fair.Process(ctx, "n", func() error { return nil }))
+
+	var wg sync.WaitGroup
+	enqueued := 0
+	for _, tenant := range []string{"n", "q"} {
+		for range 15 {
+			wg.Add(1)
+			enqueued++
+			go func() {
+				defer wg.Done()
+				err := fair.Process(ctx, tenant, func() error {
+					mu.Lock()
+					defer mu.Unlock()
+					order.WriteString(tenant)
+					return nil
+				})
+				verify.NoError(t, err)
+			}()
+			err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+				return fair.Throttle().Waiting() == enqueued, nil
+			})
+			verify.NoError(t, err)
+		}
+	}
+	fair.Throttle().SetLimit(100)
+	wg.Wait()
+
+	// The first task has been waiting for the limiter before the others
+	// arrived, after it the tokens are shared by the weights.
+	t.Logf("order: %s", order.String())
+	shares := order.String()[1:19]
+	verify.InRange(t, strings.Count(shares, "q"), 10, 14, "share of tenant q")
+	verify.InRange(t, strings.Count(shares, "n"), 4, 8, "share of tenant n")
+}
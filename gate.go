@@ -0,0 +1,114 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// gate lets waiting tasks take their turn one after another. The order is
+// defined by the keys of the waiters, lower keys first, and by their arrival
+// in case of equal keys. Only the waiter holding the turn is allowed to
+// wait for the limiter.
+type gate struct {
+	mu    sync.Mutex
+	queue waiters
+	seq   uint64
+	busy  bool
+}
+
+// enter lets the caller wait for its turn. When returning without error
+// the caller has to call leave() after it is done.
+func (g *gate) enter(ctx context.Context, key float64) error {
+	g.mu.Lock()
+	if !g.busy {
+		g.busy = true
+		g.mu.Unlock()
+		return nil
+	}
+	w := &waiter{
+		key:  key,
+		seq:  g.seq,
+		turn: make(chan struct{}),
+	}
+	g.seq++
+	heap.Push(&g.queue, w)
+	g.mu.Unlock()
+	select {
+	case <-w.turn:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		if w.index >= 0 {
+			// Still waiting, so simply leave the queue.
+			heap.Remove(&g.queue, w.index)
+			g.mu.Unlock()
+			return ctx.Err()
+		}
+		g.mu.Unlock()
+		// Turn has been given in the meantime, pass it on.
+		g.leave()
+		return ctx.Err()
+	}
+}
+
+// leave passes the turn to the next waiter.
+func (g *gate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.queue.Len() == 0 {
+		g.busy = false
+		return
+	}
+	w := heap.Pop(&g.queue).(*waiter)
+	close(w.turn)
+}
+
+// waiter is one task waiting for its turn.
+type waiter struct {
+	key   float64
+	seq   uint64
+	index int
+	turn  chan struct{}
+}
+
+// waiters implements heap.Interface for the waiters of a gate.
+type waiters []*waiter
+
+func (ws waiters) Len() int { return len(ws) }
+
+func (ws waiters) Less(i, j int) bool {
+	if ws[i].key != ws[j].key {
+		return ws[i].key < ws[j].key
+	}
+	return ws[i].seq < ws[j].seq
+}
+
+func (ws waiters) Swap(i, j int) {
+	ws[i], ws[j] = ws[j], ws[i]
+	ws[i].index = i
+	ws[j].index = j
+}
+
+func (ws *waiters) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*ws)
+	*ws = append(*ws, w)
+}
+
+func (ws *waiters) Pop() any {
+	old := *ws
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*ws = old[:n-1]
+	return w
+}
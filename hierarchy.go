@@ -0,0 +1,203 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KeyedThrottle manages one Throttle per key, e.g. per tenant or per user.
+// The throttles are created on first use with the same limit, burst, and
+// options. They are kept until removed with Remove(), so callers with many
+// or changing keys have to remove the throttles of idle keys themselves.
+type KeyedThrottle struct {
+	mu        sync.Mutex
+	limit     Limit
+	burst     int
+	options   []ThrottleOption
+	throttles map[string]*Throttle
+}
+
+// NewKeyedThrottle creates a new KeyedThrottle creating throttles with the
+// specified limit, burst, and options.
+func NewKeyedThrottle(limit Limit, burst int, options ...ThrottleOption) *KeyedThrottle {
+	return &KeyedThrottle{
+		limit:     limit,
+		burst:     burst,
+		options:   options,
+		throttles: make(map[string]*Throttle),
+	}
+}
+
+// Throttle returns the throttle of the key, creating it if needed.
+func (k *KeyedThrottle) Throttle(key string) *Throttle {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	t, ok := k.throttles[key]
+	if !ok {
+		t = NewThrottle(k.limit, k.burst, k.options...)
+		k.throttles[key] = t
+	}
+	return t
+}
+
+// Remove removes the throttle of the key, e.g. when a user logged out. It
+// will be created again on next use.
+func (k *KeyedThrottle) Remove(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.throttles, key)
+}
+
+// Process processes a task with the throttle of the key.
+func (k *KeyedThrottle) Process(ctx context.Context, key string, task Task) error {
+	return k.Throttle(key).Process(ctx, task)
+}
+
+// HierarchicalThrottle chains the limits of multiple levels, e.g. a global
+// one, one per tenant, and one per user. A task consumes a token of every
+// level or none. If one level can not grant it, the tokens reserved in the
+// other levels are given back. The task waits until all levels allow its
+// processing.
+type HierarchicalThrottle struct {
+	global *Throttle
+	levels []*KeyedThrottle
+}
+
+// NewHierarchicalThrottle creates a new HierarchicalThrottle with a global
+// throttle and keyed throttles for the lower levels. The global throttle
+// is optional, it may be nil.
+func NewHierarchicalThrottle(global *Throttle, levels ...*KeyedThrottle) *HierarchicalThrottle {
+	return &HierarchicalThrottle{
+		global: global,
+		levels: levels,
+	}
+}
+
+// Process processes a task under the context, waiting if necessary. The path
+// contains the keys for the levels, e.g. tenant and user. The task is admitted
+// by each throttle on the path like by Throttle.Process(), with its queue,
+// order, and maximum tasks in flight. The tokens are reserved in all limiters
+// together with the shortest maximum wait. Each throttle rejects the task when
+// closed and adapts its limit to the result. The global throttle, or the first
+// one on the path without it, recovers the panics of the task if configured.
+func (h *HierarchicalThrottle) Process(ctx context.Context, path []string, task Task) (err error) {
+	if len(path) != len(h.levels) {
+		return fmt.Errorf("throttle path has %d keys for %d levels", len(path), len(h.levels))
+	}
+	throttles := make([]*Throttle, 0, len(h.levels)+1)
+	if h.global != nil {
+		throttles = append(throttles, h.global)
+	}
+	for i, level := range h.levels {
+		throttles = append(throttles, level.Throttle(path[i]))
+	}
+	if len(throttles) == 0 {
+		return errors.New("throttle without levels")
+	}
+	ran := 0
+	for _, t := range throttles {
+		var leave func(ran int, err error)
+		ctx, leave, err = t.enter(ctx, 1)
+		if err != nil {
+			return err
+		}
+		defer func() { leave(ran, err) }()
+	}
+	for i, t := range throttles {
+		if err := t.enqueue(); err != nil {
+			dequeue(throttles[:i])
+			return err
+		}
+	}
+	r, err := h.admit(ctx, throttles)
+	dequeue(throttles)
+	if err != nil {
+		return err
+	}
+	ran, err = h.run(ctx, throttles, r, task)
+	return err
+}
+
+// admit lets the task wait for its turn and a free slot on all levels, from
+// the lowest one up to the global one, and then for the tokens of all
+// limiters.
+func (h *HierarchicalThrottle) admit(ctx context.Context, throttles []*Throttle) (Reservation, error) {
+	taken := 0
+	freeSlots := func() {
+		for _, t := range throttles[len(throttles)-taken:] {
+			t.freeSlot()
+		}
+	}
+	for i := len(throttles) - 1; i >= 0; i-- {
+		t := throttles[i]
+		leave, err := t.turn(ctx, t.priorityKey(0))
+		if err != nil {
+			freeSlots()
+			return nil, err
+		}
+		defer leave()
+		if err := t.slot(ctx); err != nil {
+			freeSlots()
+			return nil, err
+		}
+		taken++
+	}
+	limiters := make([]Limiter, len(throttles))
+	var maxWait time.Duration
+	for i, t := range throttles {
+		limiters[i] = t.limiter
+		if t.maxWait > 0 && (maxWait == 0 || t.maxWait < maxWait) {
+			maxWait = t.maxWait
+		}
+	}
+	r, err := reserve(ctx, &multiLimiter{limiters: limiters}, 1, maxWait)
+	if err != nil {
+		freeSlots()
+		return nil, err
+	}
+	return r, nil
+}
+
+// run processes an admitted task and adapts the limits of all levels. Their
+// slots are freed afterwards. It returns 1 if the task has been processed,
+// 0 if not.
+func (h *HierarchicalThrottle) run(ctx context.Context, throttles []*Throttle, r Reservation, task Task) (int, error) {
+	for _, t := range throttles {
+		defer t.freeSlot()
+	}
+	// Give the tokens back if the context ended right after admission.
+	if ctx.Err() != nil {
+		refund(r, time.Now())
+		return 0, fmt.Errorf("throttle context done before task start: %w", doneErr(ctx))
+	}
+	_, err := throttles[0].call(costOne(task))
+	if errors.Is(err, ErrRefund) {
+		refund(r, time.Now())
+		return 1, err
+	}
+	for _, t := range throttles {
+		if t.aimd != nil {
+			t.adapt(err)
+		}
+	}
+	return 1, err
+}
+
+// dequeue removes the task from the queues of the throttles.
+func dequeue(throttles []*Throttle) {
+	for _, t := range throttles {
+		t.waiting.Add(-1)
+	}
+}
@@ -0,0 +1,131 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestHierarchicalThrottle verifies the rollback of reserved tokens if one
+// level rejects a task.
+func TestHierarchicalThrottle(t *testing.T) {
+	global := wait.NewThrottle(1, 5)
+	tenants := wait.NewKeyedThrottle(1, 3)
+	users := wait.NewKeyedThrottle(1, 1, wait.WithMaxWait(10*time.Millisecond))
+	h := wait.NewHierarchicalThrottle(global, tenants, users)
+	ctx := context.Background()
+	task := func() error { return nil }
+
+	verify.NoError(t, h.Process(ctx, []string{"a", "u1"}, task))
+	verify.AboutEqual(t, global.Tokens(), 4.0, 0.1)
+	verify.AboutEqual(t, tenants.Throttle("a").Tokens(), 2.0, 0.1)
+	verify.AboutEqual(t, users.Throttle("u1").Tokens(), 0.0, 0.1)
+
+	// The user level rejects, the other levels get their tokens back.
+	err := h.Process(ctx, []string{"a", "u1"}, task)
+	verify.True(t, errors.Is(err, wait.ErrThrottled))
+	verify.AboutEqual(t, global.Tokens(), 4.0, 0.1)
+	verify.AboutEqual(t, tenants.Throttle("a").Tokens(), 2.0, 0.1)
+
+	// The tenant level is exhausted by other users.
+	verify.NoError(t, h.Process(ctx, []string{"a", "u2"}, task))
+	verify.NoError(t, h.Process(ctx, []string{"a", "u3"}, task))
+	err = h.Process(ctx, []string{"a", "u4"}, task)
+	verify.True(t, errors.Is(err, wait.ErrThrottled))
+	verify.AboutEqual(t, global.Tokens(), 2.0, 0.1)
+	verify.AboutEqual(t, users.Throttle("u4").Tokens(), 1.0, 0.1)
+
+	// Other tenants are not affected.
+	verify.NoError(t, h.Process(ctx, []string{"b", "u4"}, task))
+
+	err = h.Process(ctx, []string{"a"}, task)
+	verify.ErrorContains(t, err, "throttle path has 1 keys for 2 levels")
+}
+
+// TestHierarchicalThrottleWait verifies the waiting until all levels
+// allow the processing.
+func TestHierarchicalThrottleWait(t *testing.T) {
+	h := wait.NewHierarchicalThrottle(wait.NewThrottle(20, 1), wait.NewKeyedThrottle(10, 1))
+	ctx := context.Background()
+	task := func() error { return nil }
+
+	verify.NoError(t, h.Process(ctx, []string{"a"}, task))
+
+	// Another key only waits for the global level.
+	start := time.Now()
+	verify.NoError(t, h.Process(ctx, []string{"b"}, task))
+	verify.DurationAboutEqual(t, time.Since(start), 50*time.Millisecond, 20*time.Millisecond)
+
+	// The same key waits for its own level too.
+	verify.NoError(t, h.Process(ctx, []string{"a"}, task))
+	verify.DurationAboutEqual(t, time.Since(start), 100*time.Millisecond, 20*time.Millisecond)
+}
+
+// TestHierarchicalThrottleOptions verifies that the options of the
+// throttles on all levels are honoured.
+func TestHierarchicalThrottleOptions(t *testing.T) {
+	global := wait.NewThrottle(wait.InfLimit, 100)
+	tenants := wait.NewKeyedThrottle(wait.InfLimit, 100)
+	users := wait.NewKeyedThrottle(wait.InfLimit, 100, wait.WithMaxInFlight(1))
+	h := wait.NewHierarchicalThrottle(global, tenants, users)
+	ctx := context.Background()
+	cc := &concurrencyCounter{}
+	task := func() error {
+		cc.incr()
+		defer cc.decr()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	// The user level allows only one task in flight.
+	var wg sync.WaitGroup
+	wg.Add(5)
+	for range 5 {
+		go func() {
+			defer wg.Done()
+			verify.NoError(t, h.Process(ctx, []string{"a", "u1"}, task))
+		}()
+	}
+	wg.Wait()
+	verify.Equal(t, cc.max(), 1)
+	verify.Equal(t, users.Throttle("u1").InFlight(), 0)
+	verify.Equal(t, users.Throttle("u1").Waiting(), 0)
+
+	// The tenant level rejects tasks if its queue is full.
+	tenants = wait.NewKeyedThrottle(10, 1, wait.WithMaxQueue(1))
+	h = wait.NewHierarchicalThrottle(global, tenants, users)
+	verify.NoError(t, h.Process(ctx, []string{"a", "u1"}, task))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		verify.NoError(t, h.Process(ctx, []string{"a", "u2"}, task))
+	}()
+	err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+		return tenants.Throttle("a").Waiting() == 1, nil
+	})
+	verify.NoError(t, err)
+	err = h.Process(ctx, []string{"a", "u3"}, task)
+	verify.True(t, errors.Is(err, wait.ErrThrottled))
+	wg.Wait()
+	verify.Equal(t, global.Waiting(), 0)
+	verify.Equal(t, users.Throttle("u3").Waiting(), 0)
+
+	// A closed tenant throttle rejects tasks.
+	verify.NoError(t, tenants.Throttle("b").Close())
+	err = h.Process(ctx, []string{"b", "u1"}, task)
+	verify.True(t, errors.Is(err, wait.ErrClosed))
+	verify.NoError(t, h.Process(ctx, []string{"a", "u1"}, task))
+}
@@ -0,0 +1,269 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter defines the algorithm a Throttle uses to decide when tasks may be
+// processed. The limit is the average number of tokens per second, the
+// burst the maximum number of tokens available at once. Each task needs one
+// token. Implementations have to be safe for concurrent use.
+type Limiter interface {
+	// Reserve reserves n tokens at the given time. The reservation tells
+	// when the tokens can be used.
+	Reserve(now time.Time, n int) Reservation
+
+	// TokensAt returns the number of tokens available at the given time.
+	TokensAt(now time.Time) float64
+
+	// Limit returns the current limit.
+	Limit() Limit
+
+	// SetLimit changes the limit.
+	SetLimit(limit Limit)
+
+	// Burst returns the current burst.
+	Burst() int
+
+	// SetBurst changes the burst.
+	SetBurst(burst int)
+}
+
+// Reservation holds the information about tokens reserved by a Limiter.
+type Reservation interface {
+	// OK returns false if the limiter can never provide the tokens.
+	OK() bool
+
+	// DelayFrom returns the duration from the given time until the
+	// reserved tokens can be used.
+	DelayFrom(now time.Time) time.Duration
+
+	// CancelAt gives the reserved tokens back to the limiter if the
+	// time to use them has not been reached at the given time.
+	CancelAt(now time.Time)
+}
+
+// Refunder is an optional interface of a Reservation. It allows to give the
+// tokens back even after the time to use them has been reached, e.g. if a
+// task hasn't touched the limited resource.
+type Refunder interface {
+	// RefundAt gives the reserved tokens back to the limiter as far as
+	// the algorithm allows it.
+	RefundAt(now time.Time)
+}
+
+// NewTokenBucketLimiter returns the default Limiter of a Throttle. The bucket
+// is refilled continuously with limit tokens per second and holds up to burst
+// tokens.
+func NewTokenBucketLimiter(limit Limit, burst int) Limiter {
+	return &tokenBucket{
+		limiter: rate.NewLimiter(limit, burst),
+	}
+}
+
+// tokenBucket adapts a rate.Limiter to the Limiter interface.
+type tokenBucket struct {
+	limiter *rate.Limiter
+}
+
+func (tb *tokenBucket) Reserve(now time.Time, n int) Reservation {
+	return tb.reserveFrom(now, now, n)
+}
+
+// reserveFrom takes the tokens at now even if they are used later. Other
+// than with windows a later use never exceeds the limit of a token bucket.
+func (tb *tokenBucket) reserveFrom(now, from time.Time, n int) Reservation {
+	return &bucketReservation{
+		Reservation: tb.limiter.ReserveN(now, n),
+		limiter:     tb.limiter,
+		n:           n,
+		from:        from,
+	}
+}
+
+func (tb *tokenBucket) TokensAt(now time.Time) float64 {
+	return tb.limiter.TokensAt(now)
+}
+
+func (tb *tokenBucket) Limit() Limit {
+	return tb.limiter.Limit()
+}
+
+func (tb *tokenBucket) SetLimit(limit Limit) {
+	tb.limiter.SetLimit(limit)
+}
+
+func (tb *tokenBucket) Burst() int {
+	return tb.limiter.Burst()
+}
+
+func (tb *tokenBucket) SetBurst(burst int) {
+	tb.limiter.SetBurst(burst)
+}
+
+// bucketReservation adds the refunding to the reservation of a rate.Limiter.
+type bucketReservation struct {
+	*rate.Reservation
+	limiter *rate.Limiter
+	n       int
+	from    time.Time
+}
+
+func (br *bucketReservation) DelayFrom(now time.Time) time.Duration {
+	return max(br.Reservation.DelayFrom(now), br.from.Sub(now))
+}
+
+func (br *bucketReservation) RefundAt(now time.Time) {
+	if !br.OK() || br.n == 0 {
+		return
+	}
+	if br.Reservation.DelayFrom(now) > 0 {
+		br.CancelAt(now)
+	} else {
+		// A negative reservation adds the tokens again, the limiter
+		// caps them by its burst.
+		br.limiter.ReserveN(now, -br.n)
+	}
+	br.n = 0
+}
+
+// NewLeakyBucketLimiter returns a Limiter letting tasks pass in constant
+// intervals of 1/limit seconds. It has no burst, the burst is always 1 and
+// cannot be changed.
+func NewLeakyBucketLimiter(limit Limit) Limiter {
+	return &leakyBucket{
+		limit: limit,
+	}
+}
+
+// leakyBucket implements the leaky bucket algorithm as a meter.
+type leakyBucket struct {
+	mu    sync.Mutex
+	limit Limit
+	next  time.Time
+}
+
+func (lb *leakyBucket) Reserve(now time.Time, n int) Reservation {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	switch {
+	case lb.limit == InfLimit:
+		return &reservation{ok: true, at: now}
+	case n > 1 || lb.limit <= 0:
+		return &reservation{}
+	}
+	at := now
+	if lb.next.After(at) {
+		at = lb.next
+	}
+	lb.next = at.Add(lb.interval())
+	next := lb.next
+	return &reservation{
+		ok: true,
+		at: at,
+		cancel: func(_ time.Time) {
+			lb.mu.Lock()
+			defer lb.mu.Unlock()
+			// Only the latest reservation can be given back.
+			if lb.next.Equal(next) {
+				lb.next = at
+			}
+		},
+	}
+}
+
+func (lb *leakyBucket) TokensAt(now time.Time) float64 {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	switch {
+	case lb.limit == InfLimit:
+		return 1
+	case lb.limit <= 0:
+		return 0
+	case !lb.next.After(now):
+		return 1
+	}
+	return 1 - float64(lb.next.Sub(now))/float64(lb.interval())
+}
+
+func (lb *leakyBucket) Limit() Limit {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	return lb.limit
+}
+
+func (lb *leakyBucket) SetLimit(limit Limit) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	lb.limit = limit
+}
+
+func (lb *leakyBucket) Burst() int {
+	return 1
+}
+
+func (lb *leakyBucket) SetBurst(_ int) {}
+
+// interval returns the duration between two tasks. The mutex has to be locked.
+func (lb *leakyBucket) interval() time.Duration {
+	return time.Duration(float64(time.Second) / float64(lb.limit))
+}
+
+
+// reservation is the Reservation of the limiters of this package.
+type reservation struct {
+	ok     bool
+	at     time.Time
+	cancel func(now time.Time)
+	err    error
+}
+
+func (r *reservation) OK() bool {
+	return r.ok
+}
+
+func (r *reservation) DelayFrom(now time.Time) time.Duration {
+	if !r.ok {
+		return InfDuration
+	}
+	return max(r.at.Sub(now), 0)
+}
+
+func (r *reservation) CancelAt(now time.Time) {
+	if r.at.Before(now) {
+		return
+	}
+	r.RefundAt(now)
+}
+
+func (r *reservation) RefundAt(now time.Time) {
+	if !r.ok || r.cancel == nil {
+		return
+	}
+	r.cancel(now)
+	r.cancel = nil
+}
+
+// refund gives the tokens of a reservation back. If it doesn't implement
+// Refunder they are only given back if they haven't been used yet.
+func refund(r Reservation, now time.Time) {
+	if rf, ok := r.(Refunder); ok {
+		rf.RefundAt(now)
+		return
+	}
+	r.CancelAt(now)
+}
@@ -0,0 +1,220 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestLimiterBorderBurst compares the algorithms when tasks arrive around
+// the border of two windows.
+func TestLimiterBorderBurst(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter wait.Limiter
+		allowed int
+	}{
+		{
+			name:    "token bucket refills 2 tokens in 200ms",
+			limiter: wait.NewTokenBucketLimiter(10, 10),
+			allowed: 12,
+		}, {
+			name:    "fixed window allows twice the burst",
+			limiter: wait.NewFixedWindowLimiter(10, time.Second),
+			allowed: 20,
+		}, {
+			name:    "sliding window log is exact",
+			limiter: wait.NewSlidingWindowLogLimiter(10, time.Second),
+			allowed: 10,
+		}, {
+			name:    "sliding window counter weights previous window",
+			limiter: wait.NewSlidingWindowCounterLimiter(10, time.Second),
+			allowed: 11,
+		}, {
+			name:    "leaky bucket allows no burst",
+			limiter: wait.NewLeakyBucketLimiter(10),
+			allowed: 2,
+		},
+	}
+	base := time.Unix(1_000_000, 0)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allowed := 0
+			for _, offset := range []time.Duration{900 * time.Millisecond, 1100 * time.Millisecond} {
+				now := base.Add(offset)
+				for range 10 {
+					if allow(test.limiter, now) {
+						allowed++
+					}
+				}
+			}
+			verify.Equal(t, allowed, test.allowed)
+		})
+	}
+}
+
+// TestLimiterDelays compares the delays of the algorithms for tasks
+// all arriving at the same time.
+func TestLimiterDelays(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter wait.Limiter
+		delays  []time.Duration
+	}{
+		{
+			name:    "token bucket",
+			limiter: wait.NewTokenBucketLimiter(2, 2),
+			delays:  []time.Duration{0, 0, 500, 1000, 1500},
+		}, {
+			name:    "fixed window",
+			limiter: wait.NewFixedWindowLimiter(2, time.Second),
+			delays:  []time.Duration{0, 0, 1000, 1000, 2000},
+		}, {
+			name:    "sliding window log",
+			limiter: wait.NewSlidingWindowLogLimiter(2, time.Second),
+			delays:  []time.Duration{0, 0, 1000, 1000, 2000},
+		}, {
+			name:    "sliding window counter",
+			limiter: wait.NewSlidingWindowCounterLimiter(2, time.Second),
+			delays:  []time.Duration{0, 0, 1500, 2000, 3000},
+		}, {
+			name:    "leaky bucket",
+			limiter: wait.NewLeakyBucketLimiter(2),
+			delays:  []time.Duration{0, 500, 1000, 1500, 2000},
+		},
+	}
+	now := time.Unix(1_000_000, 0)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, delay := range test.delays {
+				r := test.limiter.Reserve(now, 1)
+				verify.True(t, r.OK())
+				verify.Equal(t, r.DelayFrom(now), delay*time.Millisecond, fmt.Sprintf("delay of reservation %d", i))
+			}
+			// Giving back the last reservation lets the next one get the same delay.
+			r := test.limiter.Reserve(now, 1)
+			delay := r.DelayFrom(now)
+			r.CancelAt(now)
+			verify.Equal(t, test.limiter.Reserve(now, 1).DelayFrom(now), delay)
+		})
+	}
+}
+
+// TestLimiterConfiguration verifies limit and burst of the algorithms.
+func TestLimiterConfiguration(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+
+	// Window based limiters express their limit by burst and window.
+	for _, limiter := range []wait.Limiter{
+		wait.NewFixedWindowLimiter(100, time.Minute),
+		wait.NewSlidingWindowLogLimiter(100, time.Minute),
+		wait.NewSlidingWindowCounterLimiter(100, time.Minute),
+	} {
+		verify.AboutEqual(t, float64(limiter.Limit()), 100.0/60.0, 0.0001)
+		verify.Equal(t, limiter.Burst(), 100)
+		verify.Equal(t, limiter.TokensAt(now), 100.0)
+
+		limiter.SetLimit(10)
+		verify.AboutEqual(t, float64(limiter.Limit()), 10.0, 0.0001)
+		limiter.SetBurst(5)
+		verify.AboutEqual(t, float64(limiter.Limit()), 10.0, 0.0001)
+		verify.Equal(t, limiter.Burst(), 5)
+
+		verify.False(t, limiter.Reserve(now, 6).OK(), "more than burst")
+		limiter.SetLimit(wait.InfLimit)
+		verify.True(t, limiter.Reserve(now, 6).OK(), "infinite limit")
+		limiter.SetLimit(0)
+		for range 5 {
+			verify.True(t, limiter.Reserve(now, 1).OK(), "burst at limit 0")
+		}
+		verify.False(t, limiter.Reserve(now, 1).OK(), "no refill at limit 0")
+	}
+
+	// A burst of 0 stops the window based limiters until the next burst.
+	for _, limiter := range []wait.Limiter{
+		wait.NewFixedWindowLimiter(5, time.Second),
+		wait.NewSlidingWindowLogLimiter(5, time.Second),
+		wait.NewSlidingWindowCounterLimiter(5, time.Second),
+	} {
+		limiter.SetBurst(0)
+		verify.Equal(t, limiter.Limit(), wait.Limit(0))
+		verify.False(t, limiter.Reserve(now, 1).OK(), "burst of 0")
+		limiter.SetBurst(5)
+		verify.AboutEqual(t, float64(limiter.Limit()), 5.0, 0.0001)
+		for range 5 {
+			verify.Equal(t, limiter.Reserve(now, 1).DelayFrom(now), time.Duration(0))
+		}
+		r := limiter.Reserve(now, 1)
+		verify.True(t, r.OK(), "window after burst of 0")
+		verify.True(t, r.DelayFrom(now) < 2*time.Second, "window after burst of 0")
+	}
+
+	// Leaky bucket has no burst.
+	limiter := wait.NewLeakyBucketLimiter(10)
+	limiter.SetBurst(5)
+	verify.Equal(t, limiter.Burst(), 1)
+	verify.False(t, limiter.Reserve(now, 2).OK())
+	limiter.SetLimit(20)
+	verify.Equal(t, limiter.Limit(), wait.Limit(20))
+	verify.Equal(t, limiter.TokensAt(now), 1.0)
+	limiter.Reserve(now, 1)
+	verify.AboutEqual(t, limiter.TokensAt(now.Add(25*time.Millisecond)), 0.5, 0.0001)
+}
+
+// TestThrottleWithLimiter verifies a throttle using other algorithms.
+func TestThrottleWithLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter wait.Limiter
+		minimum time.Duration
+	}{
+		{
+			name:    "sliding window log",
+			limiter: wait.NewSlidingWindowLogLimiter(5, 100*time.Millisecond),
+			minimum: 100 * time.Millisecond,
+		}, {
+			name:    "leaky bucket",
+			limiter: wait.NewLeakyBucketLimiter(100),
+			minimum: 90 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			throttle := wait.NewThrottleWithLimiter(test.limiter)
+			ctx := context.Background()
+			start := time.Now()
+			for range 10 {
+				verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+			}
+			elapsed := time.Since(start)
+			verify.InRange(t, elapsed, test.minimum, test.minimum+50*time.Millisecond)
+		})
+	}
+}
+
+
+// allow checks if the limiter allows one token at the given time without
+// waiting. Otherwise the reservation is given back.
+func allow(limiter wait.Limiter, now time.Time) bool {
+	r := limiter.Reserve(now, 1)
+	if !r.OK() {
+		return false
+	}
+	if r.DelayFrom(now) > 0 {
+		r.CancelAt(now)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ThrottledListener limits the rate of accepted connections and the number
+// of concurrently open ones. Additionally the accepted connections can be
+// limited in their bandwidth.
+type ThrottledListener struct {
+	net.Listener
+	throttle   *Throttle
+	conns      chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	mu         sync.Mutex
+	readLimit  Limit
+	writeLimit Limit
+	burst      int
+}
+
+// NewThrottledListener wraps the listener with a limit and burst for
+// accepted connections per second. A maximum of open connections less
+// than or equal to 0 means no maximum. Accept blocks while the maximum
+// is reached.
+func NewThrottledListener(l net.Listener, limit Limit, burst, maxConns int) *ThrottledListener {
+	ctx, cancel := context.WithCancel(context.Background())
+	tl := &ThrottledListener{
+		Listener:   l,
+		throttle:   NewThrottle(limit, burst),
+		ctx:        ctx,
+		cancel:     cancel,
+		readLimit:  InfLimit,
+		writeLimit: InfLimit,
+	}
+	if maxConns > 0 {
+		tl.conns = make(chan struct{}, maxConns)
+	}
+	return tl
+}
+
+// Throttle returns the throttle of the accepted connections, e.g. to
+// change the limit.
+func (tl *ThrottledListener) Throttle() *Throttle {
+	return tl.throttle
+}
+
+// SetConnLimits sets the bandwidth in bytes per second for reading and
+// writing of each connection accepted afterwards. The burst is in bytes.
+func (tl *ThrottledListener) SetConnLimits(read, write Limit, burst int) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	tl.readLimit = read
+	tl.writeLimit = write
+	tl.burst = burst
+}
+
+// Accept waits until the maximum of open connections and the limit allow
+// the next connection and accepts it.
+func (tl *ThrottledListener) Accept() (net.Conn, error) {
+	if tl.conns != nil {
+		select {
+		case tl.conns <- struct{}{}:
+		case <-tl.ctx.Done():
+			return nil, fmt.Errorf("wait for connection slot: %w", net.ErrClosed)
+		}
+	}
+	r, err := tl.throttle.reserve(tl.ctx, 1)
+	if err != nil {
+		tl.release()
+		if tl.ctx.Err() != nil {
+			return nil, fmt.Errorf("wait for throttle limiter: %w", net.ErrClosed)
+		}
+		return nil, err
+	}
+	conn, err := tl.Listener.Accept()
+	if err != nil {
+		refund(r, time.Now())
+		tl.release()
+		return nil, err
+	}
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	tc := NewThrottledConn(conn, tl.readLimit, tl.writeLimit, tl.burst)
+	tc.release = tl.release
+	return tc, nil
+}
+
+// Close closes the listener and ends waiting calls of Accept.
+func (tl *ThrottledListener) Close() error {
+	tl.cancel()
+	return tl.Listener.Close()
+}
+
+// release frees the slot of a connection.
+func (tl *ThrottledListener) release() {
+	if tl.conns != nil {
+		<-tl.conns
+	}
+}
+
+// ThrottledConn limits the bandwidth of reading from and writing to a
+// connection. Waiting for the limits ends when the connection is closed.
+type ThrottledConn struct {
+	net.Conn
+	reader  *ThrottledReader
+	writer  *ThrottledWriter
+	cancel  context.CancelFunc
+	once    sync.Once
+	release func()
+}
+
+// NewThrottledConn wraps the connection with limits in bytes per second
+// for reading and writing. The burst is in bytes.
+func NewThrottledConn(conn net.Conn, read, write Limit, burst int) *ThrottledConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ThrottledConn{
+		Conn:   conn,
+		reader: NewThrottledReader(ctx, conn, read, burst),
+		writer: NewThrottledWriter(ctx, conn, write, burst),
+		cancel: cancel,
+	}
+}
+
+// Read reads from the connection limited by the read bandwidth.
+func (tc *ThrottledConn) Read(p []byte) (int, error) {
+	return tc.reader.Read(p)
+}
+
+// Write writes to the connection limited by the write bandwidth.
+func (tc *ThrottledConn) Write(p []byte) (int, error) {
+	return tc.writer.Write(p)
+}
+
+// Close closes the connection and ends waiting reads and writes.
+func (tc *ThrottledConn) Close() error {
+	tc.once.Do(func() {
+		tc.cancel()
+		if tc.release != nil {
+			tc.release()
+		}
+	})
+	return tc.Conn.Close()
+}
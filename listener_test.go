@@ -0,0 +1,109 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottledListenerRate verifies the limited rate of accepted
+// connections.
+func TestThrottledListenerRate(t *testing.T) {
+	l := listen(t, 20, 1, 0)
+	defer l.Close()
+
+	go func() {
+		for range 3 {
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err == nil {
+				defer conn.Close()
+			}
+		}
+	}()
+
+	start := time.Now()
+	for range 3 {
+		conn, err := l.Accept()
+		verify.NoError(t, err)
+		defer conn.Close()
+	}
+	verify.DurationAboutEqual(t, time.Since(start), 100*time.Millisecond, 30*time.Millisecond)
+}
+
+// TestThrottledListenerMaxConns verifies the maximum of open connections.
+func TestThrottledListenerMaxConns(t *testing.T) {
+	l := listen(t, wait.InfLimit, 0, 1)
+
+	for range 2 {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		verify.NoError(t, err)
+		defer conn.Close()
+	}
+
+	first, err := l.Accept()
+	verify.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		first.Close()
+	}()
+	start := time.Now()
+	second, err := l.Accept()
+	verify.NoError(t, err)
+	verify.DurationAboutEqual(t, time.Since(start), 50*time.Millisecond, 20*time.Millisecond)
+
+	// Closing the listener ends a waiting Accept.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Close()
+	}()
+	_, err = l.Accept()
+	verify.True(t, errors.Is(err, net.ErrClosed))
+	second.Close()
+}
+
+// TestThrottledConn verifies the limited bandwidth of accepted connections.
+func TestThrottledConn(t *testing.T) {
+	l := listen(t, wait.InfLimit, 0, 0)
+	defer l.Close()
+	l.SetConnLimits(wait.InfLimit, 10000, 1000)
+
+	data := bytes.Repeat([]byte("x"), 2000)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(data)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	verify.NoError(t, err)
+	defer conn.Close()
+	start := time.Now()
+	read, err := io.ReadAll(conn)
+	verify.NoError(t, err)
+	verify.Equal(t, len(read), 2000)
+	verify.DurationAboutEqual(t, time.Since(start), 100*time.Millisecond, 30*time.Millisecond)
+}
+
+// listen creates a throttled listener on the loopback interface.
+func listen(t *testing.T, limit wait.Limit, burst, maxConns int) *wait.ThrottledListener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	verify.NoError(t, err)
+	return wait.NewThrottledListener(l, limit, burst, maxConns)
+}
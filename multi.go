@@ -0,0 +1,154 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// maxMultiRounds limits the rounds a multi limiter tries to find a time
+// all its limiters agree on.
+const maxMultiRounds = 64
+
+// NewMultiLimiter returns a Limiter combining multiple limiters, e.g. sliding
+// windows for quotas per second, per minute, and per day. Tokens are only
+// granted if all limiters grant them at the same time, so each limiter
+// accounts them when they are used. If one limiter can never grant them the
+// reservations of all others are given back. Used as Limiter of a Throttle
+// it processes tasks only if all quotas allow it.
+//
+// Limit and burst are the smallest ones of the limiters. Changing them
+// scales the limits respectively bursts of all limiters by the same factor.
+func NewMultiLimiter(limiters ...Limiter) Limiter {
+	return &multiLimiter{
+		limiters: limiters,
+	}
+}
+
+// multiLimiter implements the combination of multiple limiters.
+type multiLimiter struct {
+	mu       sync.Mutex
+	limiters []Limiter
+}
+
+func (ml *multiLimiter) Reserve(now time.Time, n int) Reservation {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	at := now
+	for range maxMultiRounds {
+		rs := make([]Reservation, 0, len(ml.limiters))
+		latest := at
+		for _, limiter := range ml.limiters {
+			r := reserveFrom(limiter, now, at, n)
+			if !r.OK() {
+				cancelAll(rs, now)
+				return &reservation{}
+			}
+			rs = append(rs, r)
+			if act := at.Add(r.DelayFrom(at)); act.After(latest) {
+				latest = act
+			}
+		}
+		if !latest.After(at) {
+			return &reservation{
+				ok: true,
+				at: at,
+				cancel: func(now time.Time) {
+					for _, r := range rs {
+						refund(r, now)
+					}
+				},
+			}
+		}
+		// Reserve again from the latest time, so that no limiter counts
+		// the tokens as used before they are really used.
+		cancelAll(rs, now)
+		at = latest
+	}
+	return &reservation{}
+}
+
+func (ml *multiLimiter) TokensAt(now time.Time) float64 {
+	tokens := math.Inf(1)
+	for _, limiter := range ml.limiters {
+		tokens = min(tokens, limiter.TokensAt(now))
+	}
+	return tokens
+}
+
+func (ml *multiLimiter) Limit() Limit {
+	limit := InfLimit
+	for _, limiter := range ml.limiters {
+		limit = min(limit, limiter.Limit())
+	}
+	return limit
+}
+
+func (ml *multiLimiter) SetLimit(limit Limit) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	current := ml.Limit()
+	for _, limiter := range ml.limiters {
+		if current == 0 || current == InfLimit || limit == 0 || limit == InfLimit {
+			limiter.SetLimit(limit)
+			continue
+		}
+		limiter.SetLimit(limiter.Limit() * limit / current)
+	}
+}
+
+func (ml *multiLimiter) Burst() int {
+	burst := math.MaxInt
+	for _, limiter := range ml.limiters {
+		burst = min(burst, limiter.Burst())
+	}
+	return burst
+}
+
+func (ml *multiLimiter) SetBurst(burst int) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	current := ml.Burst()
+	for _, limiter := range ml.limiters {
+		if current <= 0 || burst <= 0 {
+			limiter.SetBurst(burst)
+			continue
+		}
+		scaled := float64(limiter.Burst()) * float64(burst) / float64(current)
+		limiter.SetBurst(max(int(math.Round(scaled)), 1))
+	}
+}
+
+
+// fromReserver is implemented by the limiters of this package. It reserves
+// tokens usable at the earliest at the given from time, but uses now as the
+// current time of the limiter, e.g. for dropping outdated state.
+type fromReserver interface {
+	reserveFrom(now, from time.Time, n int) Reservation
+}
+
+// reserveFrom reserves n tokens of the limiter usable at the earliest at
+// from without dropping state still needed at now.
+func reserveFrom(limiter Limiter, now, from time.Time, n int) Reservation {
+	if fr, ok := limiter.(fromReserver); ok {
+		return fr.reserveFrom(now, from, n)
+	}
+	return limiter.Reserve(from, n)
+}
+
+// cancelAll gives all reservations back.
+func cancelAll(rs []Reservation, now time.Time) {
+	for _, r := range rs {
+		r.CancelAt(now)
+	}
+}
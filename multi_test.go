@@ -0,0 +1,153 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestMultiLimiter verifies the combination of multiple quotas.
+func TestMultiLimiter(t *testing.T) {
+	// 2 per 100ms and 3 per second.
+	limiter := wait.NewMultiLimiter(
+		wait.NewSlidingWindowLogLimiter(2, 100*time.Millisecond),
+		wait.NewSlidingWindowLogLimiter(3, time.Second),
+	)
+	now := time.Unix(1_000_000, 0)
+	for i, delay := range []time.Duration{0, 0, 100, 1000, 1000, 1100, 2000} {
+		r := limiter.Reserve(now, 1)
+		verify.True(t, r.OK())
+		verify.Equal(t, r.DelayFrom(now), delay*time.Millisecond, fmt.Sprintf("delay of reservation %d", i))
+	}
+	verify.AboutEqual(t, float64(limiter.Limit()), 3.0, 0.0001)
+	verify.Equal(t, limiter.Burst(), 2)
+
+	// Changing the limit scales all quotas.
+	limiter.SetLimit(6)
+	verify.AboutEqual(t, float64(limiter.Limit()), 6.0, 0.0001)
+	limiter.SetBurst(4)
+	verify.Equal(t, limiter.Burst(), 4)
+}
+
+// TestMultiLimiterBuckets verifies that the slowest of multiple token
+// buckets defines the rate.
+func TestMultiLimiterBuckets(t *testing.T) {
+	limiter := wait.NewMultiLimiter(
+		wait.NewTokenBucketLimiter(1, 1),
+		wait.NewTokenBucketLimiter(10, 1),
+	)
+	now := time.Unix(1_000_000, 0)
+	for i := range 5 {
+		r := limiter.Reserve(now, 1)
+		verify.True(t, r.OK())
+		verify.Equal(t, r.DelayFrom(now), time.Duration(i)*time.Second, fmt.Sprintf("delay of reservation %d", i))
+	}
+
+	// Cancelling gives back exactly the reserved tokens.
+	r := limiter.Reserve(now, 1)
+	r.CancelAt(now)
+	verify.Equal(t, limiter.Reserve(now, 1).DelayFrom(now), 5*time.Second)
+}
+
+// TestMultiLimiterWindows verifies that windows of different sizes count
+// the tokens when they are used.
+func TestMultiLimiterWindows(t *testing.T) {
+	// 10 per second and 20 per minute.
+	limiter := wait.NewMultiLimiter(
+		wait.NewFixedWindowLimiter(10, time.Second),
+		wait.NewFixedWindowLimiter(20, time.Minute),
+	)
+	now := time.Unix(60_000, 0)
+	seconds := make(map[time.Duration]int)
+	minutes := make(map[time.Duration]int)
+	for range 40 {
+		r := limiter.Reserve(now, 1)
+		verify.True(t, r.OK())
+		delay := r.DelayFrom(now)
+		seconds[delay.Truncate(time.Second)]++
+		minutes[delay.Truncate(time.Minute)]++
+	}
+	t.Logf("tasks per second: %v", seconds)
+	for second, count := range seconds {
+		verify.True(t, count <= 10, fmt.Sprintf("%d tasks in second %v", count, second))
+	}
+	verify.Length(t, minutes, 2)
+	verify.Equal(t, minutes[0], 20)
+	verify.Equal(t, minutes[time.Minute], 20)
+}
+
+// TestMultiLimiterRejection verifies that a rejection of one limiter
+// gives the tokens of the others back.
+func TestMultiLimiterRejection(t *testing.T) {
+	bucket := wait.NewTokenBucketLimiter(1, 5)
+	limiter := wait.NewMultiLimiter(
+		bucket,
+		wait.NewFixedWindowLimiter(1, time.Hour),
+	)
+	now := time.Unix(1_000_000, 0)
+
+	verify.True(t, limiter.Reserve(now, 1).OK())
+	verify.Equal(t, bucket.TokensAt(now), 4.0)
+	verify.Equal(t, limiter.TokensAt(now), 0.0)
+	for range 3 {
+		verify.False(t, limiter.Reserve(now, 2).OK())
+	}
+	verify.Equal(t, bucket.TokensAt(now), 4.0)
+}
+
+// TestThrottleWithMultiLimiter verifies a throttle with multiple quotas.
+func TestThrottleWithMultiLimiter(t *testing.T) {
+	throttle := wait.NewThrottleWithLimiter(wait.NewMultiLimiter(
+		wait.NewTokenBucketLimiter(100, 1),
+		wait.NewSlidingWindowLogLimiter(5, 200*time.Millisecond),
+	))
+	ctx := context.Background()
+	start := time.Now()
+	for range 6 {
+		verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	}
+	verify.InRange(t, time.Since(start), 200*time.Millisecond, 250*time.Millisecond)
+
+	// Concurrent tasks are admitted with the rate of the slowest limiter.
+	throttle = wait.NewThrottleWithLimiter(wait.NewMultiLimiter(
+		wait.NewTokenBucketLimiter(20, 1),
+		wait.NewTokenBucketLimiter(100, 1),
+	))
+	start = time.Now()
+	var wg sync.WaitGroup
+	for range 11 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+		}()
+	}
+	wg.Wait()
+	verify.DurationAboutEqual(t, time.Since(start), 500*time.Millisecond, 50*time.Millisecond)
+
+	// Context is honoured.
+	throttle = wait.NewThrottleWithLimiter(wait.NewMultiLimiter(
+		wait.NewTokenBucketLimiter(100, 5),
+		wait.NewSlidingWindowLogLimiter(5, time.Second),
+	))
+	for range 5 {
+		verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	}
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err := throttle.Process(tctx, func() error { return nil })
+	verify.ErrorContains(t, err, "deadline exceeded")
+}
@@ -0,0 +1,71 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned if a recovered panic ended a task or a condition
+// check. It contains the recovered value and the stack trace of the
+// panicking goroutine.
+type PanicError struct {
+	Value any
+	Stack []byte
+
+	during string
+}
+
+// newPanicError creates a PanicError for a recovered value, the stack is
+// the one of the calling goroutine.
+func newPanicError(during string, value any) *PanicError {
+	return &PanicError{
+		Value:  value,
+		Stack:  debug.Stack(),
+		during: during,
+	}
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic during %s: %v", e.during, e.Value)
+}
+
+// Unwrap returns the recovered value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// WithPanicRecovery switches the recovery of panicking tasks on or off. By
+// default a panic is recovered and returned as *PanicError like a panicking
+// condition when polling. Otherwise it crashes the caller, respectively the
+// program when the task has been submitted.
+func WithPanicRecovery(enabled bool) ThrottleOption {
+	return func(t *Throttle) {
+		t.noRecovery = !enabled
+	}
+}
+
+// call calls the task and recovers a panic if configured. A panicking task
+// costs one token.
+func (t *Throttle) call(task CostTask) (cost int, err error) {
+	if t.noRecovery {
+		return task()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			cost = 1
+			err = newPanicError("task processing", r)
+		}
+	}()
+	return task()
+}
@@ -0,0 +1,50 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottlePanicRecovery verifies the recovery of panicking tasks.
+func TestThrottlePanicRecovery(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 0)
+	ctx := context.Background()
+
+	err := throttle.Process(ctx, func() error {
+		panic("ouch")
+	})
+	var perr *wait.PanicError
+	verify.True(t, errors.As(err, &perr))
+	verify.Equal(t, perr.Value, any("ouch"))
+	verify.Contains(t, string(perr.Stack), "TestThrottlePanicRecovery")
+	verify.ErrorContains(t, err, "panic during task processing: ouch")
+
+	// Panics of batches and submitted tasks are recovered too.
+	err = throttle.ProcessAll(ctx, func() error { return nil }, func() error { panic("ouch") })
+	verify.True(t, errors.As(err, &perr))
+	err = throttle.Submit(ctx, func() error { panic("ouch") }).Wait(ctx)
+	verify.True(t, errors.As(err, &perr))
+}
+
+// TestThrottleNoPanicRecovery verifies the switched off recovery.
+func TestThrottleNoPanicRecovery(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 0, wait.WithPanicRecovery(false))
+
+	verify.Panics(t, func() {
+		throttle.Process(context.Background(), func() error {
+			panic("ouch")
+		})
+	})
+}
@@ -0,0 +1,226 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ResetSchedule returns the next reset of a quota after the given time.
+type ResetSchedule func(after time.Time) time.Time
+
+// ResetHourly resets a quota at the top of each hour in the given
+// location. A nil location means UTC.
+func ResetHourly(loc *time.Location) ResetSchedule {
+	loc = locationOrUTC(loc)
+	return func(after time.Time) time.Time {
+		t := after.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+	}
+}
+
+// ResetDaily resets a quota at midnight in the given location. A nil
+// location means UTC.
+func ResetDaily(loc *time.Location) ResetSchedule {
+	loc = locationOrUTC(loc)
+	return func(after time.Time) time.Time {
+		t := after.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+	}
+}
+
+// ResetWeekly resets a quota at midnight of the given weekday in the
+// given location. A nil location means UTC.
+func ResetWeekly(weekday time.Weekday, loc *time.Location) ResetSchedule {
+	loc = locationOrUTC(loc)
+	return func(after time.Time) time.Time {
+		t := after.In(loc)
+		days := (int(weekday)-int(t.Weekday())+6)%7 + 1
+		return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, loc)
+	}
+}
+
+// ResetMonthly resets a quota at midnight of the first day of each month
+// in the given location. A nil location means UTC.
+func ResetMonthly(loc *time.Location) ResetSchedule {
+	loc = locationOrUTC(loc)
+	return func(after time.Time) time.Time {
+		t := after.In(loc)
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+	}
+}
+
+// QuotaLimiter is a Limiter allowing a quota of tokens per period. Other than
+// the token bucket it doesn't refill continuously, the whole quota is
+// available again at the wall-clock times defined by its schedule. Once the
+// quota is exhausted a Throttle waits for the next reset. Combined with the
+// option WithMaxWait() it rejects tasks with a *ThrottledError telling the
+// time until the reset instead.
+type QuotaLimiter struct {
+	mu       sync.Mutex
+	quota    int
+	schedule ResetSchedule
+	periods  []*period
+}
+
+// period is the current or a future period of a quota.
+type period struct {
+	start time.Time
+	end   time.Time
+	used  int
+}
+
+// NewQuotaLimiter creates a limiter with the given quota per period of
+// the schedule.
+func NewQuotaLimiter(quota int, schedule ResetSchedule) *QuotaLimiter {
+	return &QuotaLimiter{
+		quota:    quota,
+		schedule: schedule,
+	}
+}
+
+// Reserve implements Limiter. If the quota of the current period is
+// exhausted the tokens are reserved in the next period having them left.
+func (ql *QuotaLimiter) Reserve(now time.Time, n int) Reservation {
+	return ql.reserveFrom(now, now, n)
+}
+
+func (ql *QuotaLimiter) reserveFrom(now, from time.Time, n int) Reservation {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+
+	if n > ql.quota {
+		return &reservation{}
+	}
+	ql.roll(now)
+	i := 0
+	for !ql.periods[i].end.After(from) || ql.periods[i].used+n > ql.quota {
+		i++
+		if i == len(ql.periods) {
+			last := ql.periods[i-1]
+			ql.periods = append(ql.periods, &period{
+				start: last.end,
+				end:   ql.schedule(last.end),
+			})
+		}
+	}
+	p := ql.periods[i]
+	p.used += n
+	at := from
+	if p.start.After(from) {
+		at = p.start
+	}
+	return &reservation{
+		ok: true,
+		at: at,
+		cancel: func(_ time.Time) {
+			ql.mu.Lock()
+			defer ql.mu.Unlock()
+			p.used = max(p.used-n, 0)
+		},
+	}
+}
+
+// TokensAt implements Limiter. It returns the tokens left in the period
+// containing the given time.
+func (ql *QuotaLimiter) TokensAt(now time.Time) float64 {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+
+	ql.roll(now)
+	return float64(ql.quota - ql.periods[0].used)
+}
+
+// Limit implements Limiter. It returns the quota divided by the length
+// of a period.
+func (ql *QuotaLimiter) Limit() Limit {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+
+	return Limit(float64(ql.quota) / ql.length(time.Now()).Seconds())
+}
+
+// SetLimit implements Limiter. It sets the quota to the number of tokens
+// the limit allows during a period.
+func (ql *QuotaLimiter) SetLimit(limit Limit) {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+
+	switch {
+	case limit == InfLimit:
+		ql.quota = math.MaxInt
+	case limit <= 0:
+		ql.quota = 0
+	default:
+		ql.quota = int(math.Round(float64(limit) * ql.length(time.Now()).Seconds()))
+	}
+}
+
+// Burst implements Limiter. It returns the quota.
+func (ql *QuotaLimiter) Burst() int {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+
+	return ql.quota
+}
+
+// SetBurst implements Limiter. It sets the quota.
+func (ql *QuotaLimiter) SetBurst(burst int) {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+
+	ql.quota = max(burst, 0)
+}
+
+// Remaining returns the quota left in the current period.
+func (ql *QuotaLimiter) Remaining() int {
+	return int(ql.TokensAt(time.Now()))
+}
+
+// NextReset returns the time of the next reset of the quota.
+func (ql *QuotaLimiter) NextReset() time.Time {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+
+	ql.roll(time.Now())
+	return ql.periods[0].end
+}
+
+
+// roll drops the periods ended at the given time and ensures that the
+// current period exists. The mutex has to be locked.
+func (ql *QuotaLimiter) roll(now time.Time) {
+	i := 0
+	for i < len(ql.periods) && !ql.periods[i].end.After(now) {
+		i++
+	}
+	ql.periods = ql.periods[i:]
+	if len(ql.periods) == 0 {
+		ql.periods = append(ql.periods, &period{
+			start: now,
+			end:   ql.schedule(now),
+		})
+	}
+}
+
+// length returns the length of a period. The mutex has to be locked.
+func (ql *QuotaLimiter) length(now time.Time) time.Duration {
+	ql.roll(now)
+	end := ql.periods[0].end
+	return ql.schedule(end).Sub(end)
+}
+
+// locationOrUTC returns the location or UTC if it is nil.
+func locationOrUTC(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,121 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestResetSchedules verifies the calculation of the next reset times.
+func TestResetSchedules(t *testing.T) {
+	india := time.FixedZone("IST", 5*3600+1800)
+	tests := []struct {
+		name     string
+		schedule wait.ResetSchedule
+		after    time.Time
+		expected time.Time
+	}{
+		{
+			name:     "hourly in UTC",
+			schedule: wait.ResetHourly(nil),
+			after:    time.Date(2025, 3, 10, 10, 10, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 10, 11, 0, 0, 0, time.UTC),
+		}, {
+			name:     "hourly in zone with half hour offset",
+			schedule: wait.ResetHourly(india),
+			after:    time.Date(2025, 3, 10, 10, 10, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 10, 10, 30, 0, 0, time.UTC),
+		}, {
+			name:     "daily in UTC",
+			schedule: wait.ResetDaily(time.UTC),
+			after:    time.Date(2025, 3, 10, 23, 59, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC),
+		}, {
+			name:     "daily in other zone",
+			schedule: wait.ResetDaily(india),
+			after:    time.Date(2025, 3, 10, 20, 0, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 11, 18, 30, 0, 0, time.UTC),
+		}, {
+			name:     "weekly on the same weekday",
+			schedule: wait.ResetWeekly(time.Monday, nil),
+			after:    time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC),
+		}, {
+			name:     "weekly on the day before",
+			schedule: wait.ResetWeekly(time.Monday, nil),
+			after:    time.Date(2025, 3, 9, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		}, {
+			name:     "monthly at the end of the year",
+			schedule: wait.ResetMonthly(nil),
+			after:    time.Date(2025, 12, 15, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := test.schedule(test.after)
+			verify.True(t, next.Equal(test.expected), "next reset "+next.String())
+		})
+	}
+}
+
+// TestQuotaLimiter verifies the quota per period.
+func TestQuotaLimiter(t *testing.T) {
+	limiter := wait.NewQuotaLimiter(3, wait.ResetDaily(nil))
+	now := time.Date(2025, 3, 10, 23, 59, 0, 0, time.UTC)
+	midnight := time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	verify.Equal(t, limiter.TokensAt(now), 3.0)
+	for range 3 {
+		r := limiter.Reserve(now, 1)
+		verify.True(t, r.OK())
+		verify.Equal(t, r.DelayFrom(now), time.Duration(0))
+	}
+	verify.Equal(t, limiter.TokensAt(now), 0.0)
+
+	// Exhausted quota lets the next task wait until midnight.
+	r := limiter.Reserve(now, 1)
+	verify.True(t, r.OK())
+	verify.Equal(t, r.DelayFrom(now), time.Minute)
+	verify.Equal(t, limiter.TokensAt(midnight), 2.0)
+	r.CancelAt(now)
+	verify.Equal(t, limiter.TokensAt(midnight), 3.0)
+
+	verify.False(t, limiter.Reserve(midnight, 4).OK(), "more than quota")
+	verify.Equal(t, limiter.Burst(), 3)
+}
+
+// TestThrottleWithQuota verifies a throttle rejecting tasks after the
+// quota is exhausted.
+func TestThrottleWithQuota(t *testing.T) {
+	quota := wait.NewQuotaLimiter(2, wait.ResetHourly(nil))
+	throttle := wait.NewThrottleWithLimiter(quota, wait.WithMaxWait(time.Second))
+	ctx := context.Background()
+
+	verify.Equal(t, quota.Remaining(), 2)
+	verify.True(t, quota.NextReset().Equal(time.Now().UTC().Truncate(time.Hour).Add(time.Hour)))
+	verify.AboutEqual(t, float64(quota.Limit()), 2.0/3600.0, 0.00001)
+
+	for range 2 {
+		verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	}
+	verify.Equal(t, quota.Remaining(), 0)
+
+	err := throttle.Process(ctx, func() error { return nil })
+	var terr *wait.ThrottledError
+	verify.True(t, errors.As(err, &terr))
+	verify.DurationAboutEqual(t, terr.Wait, time.Until(quota.NextReset()), time.Second)
+}
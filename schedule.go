@@ -0,0 +1,160 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// ScheduleRule defines limit and burst of a throttle for a time range on
+// the given weekdays. No weekdays mean every day. The times are durations
+// since midnight, a range with To before From lasts over midnight. Then the
+// weekdays are those the range starts at.
+type ScheduleRule struct {
+	Weekdays []time.Weekday
+	From     time.Duration
+	To       time.Duration
+	Limit    Limit
+	Burst    int
+}
+
+// Schedule defines the rules for limit and burst of a throttle over time.
+// The first rule covering a time is used. The times of the rules are in
+// the location, nil means UTC.
+type Schedule struct {
+	Location *time.Location
+	Rules    []ScheduleRule
+}
+
+// Rule returns the rule covering the given time and true, or false if
+// no rule does.
+func (s Schedule) Rule(t time.Time) (ScheduleRule, bool) {
+	i := s.index(t)
+	if i < 0 {
+		return ScheduleRule{}, false
+	}
+	return s.Rules[i], true
+}
+
+// index returns the index of the rule covering the given time, -1 if
+// there's none.
+func (s Schedule) index(t time.Time) int {
+	t = t.In(locationOrUTC(s.Location))
+	clock := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+	weekday := t.Weekday()
+	for i, rule := range s.Rules {
+		day := weekday
+		switch {
+		case rule.From <= rule.To && (clock < rule.From || clock >= rule.To):
+			continue
+		case rule.From > rule.To && clock < rule.From && clock >= rule.To:
+			continue
+		case rule.From > rule.To && clock < rule.To:
+			// Range started the day before.
+			day = (weekday + 6) % 7
+		}
+		if len(rule.Weekdays) == 0 || slices.Contains(rule.Weekdays, day) {
+			return i
+		}
+	}
+	return -1
+}
+
+// WithSchedule switches limit and burst of the throttle according to the
+// schedule. Outside of the rules the throttle has the limit and burst it
+// has been created with. Like with SetLimit() and SetBurst() tasks already
+// waiting keep their point in time when switching. Changes by those methods
+// last until the next switch.
+func WithSchedule(schedule Schedule) ThrottleOption {
+	return func(t *Throttle) {
+		t.limiter = &scheduledLimiter{
+			limiter:  t.limiter,
+			schedule: schedule,
+			limit:    t.limiter.Limit(),
+			burst:    t.limiter.Burst(),
+			current:  -1,
+		}
+	}
+}
+
+// scheduledLimiter wraps a Limiter and switches its limit and burst.
+type scheduledLimiter struct {
+	mu       sync.Mutex
+	limiter  Limiter
+	schedule Schedule
+	limit    Limit
+	burst    int
+	current  int
+}
+
+func (sl *scheduledLimiter) Reserve(now time.Time, n int) Reservation {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.switchAt(now)
+	return sl.limiter.Reserve(now, n)
+}
+
+func (sl *scheduledLimiter) TokensAt(now time.Time) float64 {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.switchAt(now)
+	return sl.limiter.TokensAt(now)
+}
+
+func (sl *scheduledLimiter) Limit() Limit {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.switchAt(time.Now())
+	return sl.limiter.Limit()
+}
+
+func (sl *scheduledLimiter) SetLimit(limit Limit) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.limiter.SetLimit(limit)
+}
+
+func (sl *scheduledLimiter) Burst() int {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.switchAt(time.Now())
+	return sl.limiter.Burst()
+}
+
+func (sl *scheduledLimiter) SetBurst(burst int) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.limiter.SetBurst(burst)
+}
+
+// switchAt sets limit and burst of the wrapped limiter if another rule
+// covers the given time than before. The mutex has to be locked.
+func (sl *scheduledLimiter) switchAt(now time.Time) {
+	i := sl.schedule.index(now)
+	if i == sl.current {
+		return
+	}
+	sl.current = i
+	limit, burst := sl.limit, sl.burst
+	if i >= 0 {
+		limit, burst = sl.schedule.Rules[i].Limit, sl.schedule.Rules[i].Burst
+	}
+	sl.limiter.SetLimit(limit)
+	sl.limiter.SetBurst(burst)
+}
@@ -0,0 +1,116 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestScheduleRules verifies the finding of the rules covering a time.
+func TestScheduleRules(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	workdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	schedule := wait.Schedule{
+		Location: berlin,
+		Rules: []wait.ScheduleRule{
+			{
+				Weekdays: workdays,
+				From:     8 * time.Hour,
+				To:       18 * time.Hour,
+				Limit:    10,
+				Burst:    1,
+			}, {
+				Weekdays: []time.Weekday{time.Friday},
+				From:     22 * time.Hour,
+				To:       6 * time.Hour,
+				Limit:    200,
+				Burst:    20,
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		time     time.Time
+		expected wait.Limit
+		ok       bool
+	}{
+		{
+			name:     "business hours",
+			time:     time.Date(2025, 3, 10, 9, 0, 0, 0, berlin),
+			expected: 10,
+			ok:       true,
+		}, {
+			name:     "business hours in other zone",
+			time:     time.Date(2025, 3, 10, 7, 30, 0, 0, time.UTC),
+			expected: 10,
+			ok:       true,
+		}, {
+			name: "end of business hours",
+			time: time.Date(2025, 3, 10, 18, 0, 0, 0, berlin),
+		}, {
+			name: "weekend",
+			time: time.Date(2025, 3, 15, 9, 0, 0, 0, berlin),
+		}, {
+			name:     "friday night before midnight",
+			time:     time.Date(2025, 3, 14, 23, 0, 0, 0, berlin),
+			expected: 200,
+			ok:       true,
+		}, {
+			name:     "friday night after midnight",
+			time:     time.Date(2025, 3, 15, 5, 0, 0, 0, berlin),
+			expected: 200,
+			ok:       true,
+		}, {
+			name: "thursday night after midnight",
+			time: time.Date(2025, 3, 14, 5, 0, 0, 0, berlin),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, ok := schedule.Rule(test.time)
+			verify.Equal(t, ok, test.ok)
+			verify.Equal(t, rule.Limit, test.expected)
+		})
+	}
+}
+
+// TestThrottleWithSchedule verifies the switching of limit and burst.
+func TestThrottleWithSchedule(t *testing.T) {
+	now := time.Now().UTC()
+	clock := now.Sub(now.Truncate(24 * time.Hour))
+	always := wait.Schedule{
+		Rules: []wait.ScheduleRule{
+			{From: 0, To: 24 * time.Hour, Limit: 20, Burst: 1},
+		},
+	}
+	never := wait.Schedule{
+		Rules: []wait.ScheduleRule{
+			{From: clock + time.Hour, To: clock + 2*time.Hour, Limit: 20, Burst: 1},
+		},
+	}
+	ctx := context.Background()
+
+	throttle := wait.NewThrottle(100, 10, wait.WithSchedule(always))
+	verify.Equal(t, throttle.Limit(), wait.Limit(20))
+	verify.Equal(t, throttle.Burst(), 1)
+	start := time.Now()
+	for range 3 {
+		verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	}
+	verify.DurationAboutEqual(t, time.Since(start), 100*time.Millisecond, 20*time.Millisecond)
+
+	throttle = wait.NewThrottle(100, 10, wait.WithSchedule(never))
+	verify.Equal(t, throttle.Limit(), wait.Limit(100))
+	verify.Equal(t, throttle.Burst(), 10)
+}
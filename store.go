@@ -0,0 +1,247 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store keeps the state of token buckets shared by multiple throttles, e.g.
+// of replicas of a service. Each bucket is identified by a key and contains
+// the tokens and the time of its last update. Both operations have to be
+// atomic for a bucket. Implementations for other backends have to follow
+// this contract:
+//
+// - An unknown bucket is created full, with burst tokens and now as last
+// update.
+//
+// - Both operations first refill the bucket with limit tokens per second
+// for the time between the last update and now, at most up to burst. If now
+// is before the last update nothing is refilled and the last update stays.
+//
+// - Take subtracts n tokens. The tokens may become negative, it's the debt
+// of reservations waiting for their tokens. It returns the tokens after
+// subtracting.
+//
+// - Refill adds n tokens, at most up to burst.
+//
+// For a Redis-like backend each operation is a script reading the tokens and
+// the last update of the key, calculating the new values as described, and
+// writing them back with an expiration of at least burst/limit seconds. All
+// replicas must use the same limit and burst for a key and their clocks
+// should be in sync, alternatively the backend uses its own clock for now.
+type Store interface {
+	// Take refills the bucket and takes n tokens. It returns the tokens
+	// after taking.
+	Take(key string, now time.Time, n float64, limit Limit, burst int) (float64, error)
+
+	// Refill refills the bucket and gives n tokens back.
+	Refill(key string, now time.Time, n float64, limit Limit, burst int) error
+}
+
+// bucket is the state of a token bucket in a store.
+type bucket struct {
+	Tokens float64 `json:"tokens"`
+	Last   int64   `json:"last"`
+}
+
+// refill adds the tokens for the time since the last update.
+func (b *bucket) refill(now time.Time, limit Limit, burst int) {
+	elapsed := now.UnixNano() - b.Last
+	if elapsed <= 0 {
+		return
+	}
+	b.Tokens = min(b.Tokens+float64(limit)*float64(elapsed)/float64(time.Second), float64(burst))
+	b.Last = now.UnixNano()
+}
+
+// newBucket returns a full bucket.
+func newBucket(now time.Time, burst int) *bucket {
+	return &bucket{
+		Tokens: float64(burst),
+		Last:   now.UnixNano(),
+	}
+}
+
+// memoryStore implements a Store in memory.
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewMemoryStore returns a Store keeping the buckets in memory, e.g. for
+// throttles sharing a budget inside of one process or for tests.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (ms *memoryStore) Take(key string, now time.Time, n float64, limit Limit, burst int) (float64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	b := ms.bucket(key, now, burst)
+	b.refill(now, limit, burst)
+	b.Tokens -= n
+	return b.Tokens, nil
+}
+
+func (ms *memoryStore) Refill(key string, now time.Time, n float64, limit Limit, burst int) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	b := ms.bucket(key, now, burst)
+	b.refill(now, limit, burst)
+	b.Tokens = min(b.Tokens+n, float64(burst))
+	return nil
+}
+
+// bucket returns the bucket of the key, creating it if needed. The mutex
+// has to be locked.
+func (ms *memoryStore) bucket(key string, now time.Time, burst int) *bucket {
+	b, ok := ms.buckets[key]
+	if !ok {
+		b = newBucket(now, burst)
+		ms.buckets[key] = b
+	}
+	return b
+}
+
+const (
+	// fileLockRetry is the time between tries to get the lock of a file store.
+	fileLockRetry = time.Millisecond
+
+	// fileLockTimeout is the maximum time to get the lock of a file store. A
+	// lock older than it is considered stale.
+	fileLockTimeout = 5 * time.Second
+)
+
+// fileStore implements a Store in a file.
+type fileStore struct {
+	path string
+}
+
+// NewFileStore returns a Store keeping the buckets in a JSON file, so that
+// processes on one host can share a budget. A lock file with the suffix
+// ".lock" serializes the access. Locks of crashed processes are removed
+// after a timeout.
+func NewFileStore(path string) Store {
+	return &fileStore{
+		path: path,
+	}
+}
+
+func (fs *fileStore) Take(key string, now time.Time, n float64, limit Limit, burst int) (float64, error) {
+	var tokens float64
+	err := fs.update(key, now, burst, func(b *bucket) {
+		b.refill(now, limit, burst)
+		b.Tokens -= n
+		tokens = b.Tokens
+	})
+	return tokens, err
+}
+
+func (fs *fileStore) Refill(key string, now time.Time, n float64, limit Limit, burst int) error {
+	return fs.update(key, now, burst, func(b *bucket) {
+		b.refill(now, limit, burst)
+		b.Tokens = min(b.Tokens+n, float64(burst))
+	})
+}
+
+// update changes the bucket of the key while holding the lock.
+func (fs *fileStore) update(key string, now time.Time, burst int, change func(b *bucket)) error {
+	unlock, err := fs.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	buckets := make(map[string]*bucket)
+	data, err := os.ReadFile(fs.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("read file store: %w", err)
+	default:
+		if err := json.Unmarshal(data, &buckets); err != nil {
+			return fmt.Errorf("read file store: %w", err)
+		}
+	}
+	b, ok := buckets[key]
+	if !ok {
+		b = newBucket(now, burst)
+		buckets[key] = b
+	}
+	change(b)
+	data, err = json.Marshal(buckets)
+	if err != nil {
+		return fmt.Errorf("write file store: %w", err)
+	}
+	// Write a temporary file and rename it, so that a crash doesn't leave
+	// a broken store.
+	tmp := fs.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write file store: %w", err)
+	}
+	if err := os.Rename(tmp, fs.path); err != nil {
+		return fmt.Errorf("write file store: %w", err)
+	}
+	return nil
+}
+
+// lock creates the lock file containing a token of the owner and returns
+// the function to remove it. Only the owner removes its lock, so that a lock
+// taken over after being stale isn't removed by the former owner.
+func (fs *fileStore) lock() (func(), error) {
+	path := fs.path + ".lock"
+	token := rand.Text()
+	timeout := time.Now().Add(fileLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_, err = f.WriteString(token)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("lock file store: %w", err)
+			}
+			return func() { unlock(path, token) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("lock file store: %w", err)
+		}
+		// Read the owner before checking the age, so that a lock created
+		// in between isn't removed.
+		if owner, err := os.ReadFile(path); err == nil {
+			if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > fileLockTimeout {
+				unlock(path, string(owner))
+				continue
+			}
+		}
+		if time.Now().After(timeout) {
+			return nil, fmt.Errorf("lock file store: timeout after %v", fileLockTimeout)
+		}
+		time.Sleep(fileLockRetry)
+	}
+}
+
+// unlock removes the lock file if it belongs to the owner with the token.
+func unlock(path, token string) {
+	if owner, err := os.ReadFile(path); err == nil && string(owner) == token {
+		os.Remove(path)
+	}
+}
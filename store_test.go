@@ -0,0 +1,114 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestStores verifies the contract of the stores.
+func TestStores(t *testing.T) {
+	stores := map[string]wait.Store{
+		"memory": wait.NewMemoryStore(),
+		"file":   wait.NewFileStore(filepath.Join(t.TempDir(), "buckets.json")),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+			// A new bucket is full.
+			tokens, err := store.Take("a", now, 1, 10, 5)
+			verify.NoError(t, err)
+			verify.Equal(t, tokens, 4.0)
+
+			// Taking more tokens leads to debt.
+			tokens, err = store.Take("a", now, 5, 10, 5)
+			verify.NoError(t, err)
+			verify.Equal(t, tokens, -1.0)
+
+			// Buckets are refilled over time.
+			tokens, err = store.Take("a", now.Add(300*time.Millisecond), 0, 10, 5)
+			verify.NoError(t, err)
+			verify.AboutEqual(t, tokens, 2.0, 0.001)
+			verify.NoError(t, store.Refill("a", now.Add(300*time.Millisecond), 2, 10, 5))
+			tokens, err = store.Take("a", now.Add(300*time.Millisecond), 0, 10, 5)
+			verify.NoError(t, err)
+			verify.AboutEqual(t, tokens, 4.0, 0.001)
+
+			// Tokens are capped at the burst.
+			verify.NoError(t, store.Refill("a", now.Add(time.Hour), 3, 10, 5))
+			tokens, err = store.Take("a", now.Add(time.Hour), 0, 10, 5)
+			verify.NoError(t, err)
+			verify.Equal(t, tokens, 5.0)
+
+			// Keys are independent.
+			tokens, err = store.Take("b", now, 0, 10, 5)
+			verify.NoError(t, err)
+			verify.Equal(t, tokens, 5.0)
+		})
+	}
+}
+
+// TestFileStoreStaleLock verifies the removal of a stale lock.
+func TestFileStoreStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buckets.json")
+	lock := path + ".lock"
+	verify.NoError(t, os.WriteFile(lock, nil, 0o600))
+	old := time.Now().Add(-time.Minute)
+	verify.NoError(t, os.Chtimes(lock, old, old))
+
+	store := wait.NewFileStore(path)
+	tokens, err := store.Take("a", time.Now(), 1, 10, 5)
+	verify.NoError(t, err)
+	verify.Equal(t, tokens, 4.0)
+	_, err = os.Stat(lock)
+	verify.True(t, os.IsNotExist(err), "lock removed")
+
+	// A stale lock of another owner is removed too.
+	verify.NoError(t, os.WriteFile(lock, []byte("other"), 0o600))
+	verify.NoError(t, os.Chtimes(lock, old, old))
+	tokens, err = store.Take("b", time.Now(), 1, 10, 5)
+	verify.NoError(t, err)
+	verify.Equal(t, tokens, 4.0)
+	_, err = os.Stat(lock)
+	verify.True(t, os.IsNotExist(err), "lock removed")
+}
+
+// TestFileStoreConcurrent verifies the serialized access of multiple
+// stores using the same file.
+func TestFileStoreConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buckets.json")
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := wait.NewFileStore(path)
+			for range 10 {
+				_, err := store.Take("a", now, 1, 10, 1000)
+				verify.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	tokens, err := wait.NewFileStore(path).Take("a", now, 0, 10, 1000)
+	verify.NoError(t, err)
+	verify.Equal(t, tokens, 900.0)
+	_, err = os.Stat(path + ".lock")
+	verify.True(t, os.IsNotExist(err), "lock removed")
+}
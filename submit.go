@@ -0,0 +1,221 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Future is the result of a task submitted to a Throttle.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+// newFuture creates a future not yet done.
+func newFuture() *Future {
+	return &Future{
+		done: make(chan struct{}),
+	}
+}
+
+// Done returns a channel closed when the task has been processed or
+// could not be processed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the error of the task or why it could not be processed. It
+// is nil as long as the future is not done.
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+// Wait waits until the future is done and returns its error. If the context
+// ends before, its error is returned. The task isn't affected by this.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return fmt.Errorf("wait for future: %w", ctx.Err())
+	}
+}
+
+// complete sets the error and marks the future as done.
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// ValueFuture is the result of a task returning a value submitted to a
+// Throttle with SubmitValue().
+type ValueFuture[T any] struct {
+	*Future
+	value T
+}
+
+// Value waits until the future is done and returns the value and the error
+// of the task. If the context ends before, its error is returned.
+func (f *ValueFuture[T]) Value(ctx context.Context) (T, error) {
+	if err := f.Wait(ctx); err != nil {
+		var zero T
+		return zero, err
+	}
+	return f.value, nil
+}
+
+// SubmitValue submits a task returning a value to the throttle. See
+// Throttle.Submit() for the processing.
+func SubmitValue[T any](ctx context.Context, t *Throttle, task func() (T, error)) *ValueFuture[T] {
+	f := &ValueFuture[T]{}
+	f.Future = t.Submit(ctx, func() error {
+		value, err := task()
+		f.value = value
+		return err
+	})
+	return f
+}
+
+// submission is a task waiting for its admission by the dispatcher.
+type submission struct {
+	ctx    context.Context
+	task   Task
+	future *Future
+	leave  func(ran int, err error)
+}
+
+// complete completes the future of the submission without processing it.
+func (s *submission) complete(err error) {
+	s.finish(0, err)
+}
+
+// finish completes the future of the submission after ran tasks have been
+// processed.
+func (s *submission) finish(ran int, err error) {
+	s.leave(ran, err)
+	s.future.complete(err)
+}
+
+// admission is an admitted task waiting for a worker.
+type admission struct {
+	submission *submission
+	r          Reservation
+}
+
+// WithWorkers sets the number of workers processing submitted tasks. By
+// default there is one worker.
+func WithWorkers(workers int) ThrottleOption {
+	return func(t *Throttle) {
+		t.workers = workers
+	}
+}
+
+// Submit submits a task to the throttle without blocking the caller. A
+// dispatcher admits the submitted tasks in order with the limit of the
+// throttle and passes them to a pool of workers. The returned future is done
+// when the task has been processed or could not be processed, e.g. because
+// the context ended before.
+func (t *Throttle) Submit(ctx context.Context, task Task) *Future {
+	f := newFuture()
+	ctx, leave, err := t.enter(ctx, 1)
+	if err != nil {
+		f.complete(err)
+		return f
+	}
+	s := &submission{
+		ctx:    ctx,
+		task:   task,
+		future: f,
+		leave:  leave,
+	}
+	t.dispatchOnce.Do(t.startDispatcher)
+	t.submitMu.Lock()
+	if t.dispatched {
+		t.submitMu.Unlock()
+		s.complete(fmt.Errorf("submit task: %w", ErrClosed))
+		return f
+	}
+	waiting := t.waiting.Add(1)
+	if t.maxQueue > 0 && waiting > t.maxQueue {
+		t.waiting.Add(-1)
+		t.submitMu.Unlock()
+		s.complete(&ThrottledError{Wait: t.estimate(time.Now())})
+		return f
+	}
+	t.submitted = append(t.submitted, s)
+	t.submitMu.Unlock()
+	select {
+	case t.submittedc <- struct{}{}:
+	default:
+	}
+	return f
+}
+
+// startDispatcher starts the dispatcher and the workers.
+func (t *Throttle) startDispatcher() {
+	t.submittedc = make(chan struct{}, 1)
+	admissions := make(chan *admission)
+	go t.dispatch(admissions)
+	for range max(t.workers, 1) {
+		go t.work(admissions)
+	}
+}
+
+// dispatch admits the submitted tasks one after the other and passes them
+// to the workers. When the throttle is closed it ends after the remaining
+// submissions are done, and so do the workers.
+func (t *Throttle) dispatch(admissions chan<- *admission) {
+	defer close(admissions)
+	for {
+		select {
+		case <-t.submittedc:
+		case <-t.closing.Done():
+		}
+		for {
+			t.submitMu.Lock()
+			if len(t.submitted) == 0 {
+				t.dispatched = t.closing.Err() != nil
+				t.submitMu.Unlock()
+				break
+			}
+			s := t.submitted[0]
+			t.submitted[0] = nil
+			t.submitted = t.submitted[1:]
+			t.submitMu.Unlock()
+
+			r, err := t.admit(s.ctx, t.priorityKey(0), t.limiter, 1)
+			if err != nil {
+				s.complete(err)
+				continue
+			}
+			admissions <- &admission{
+				submission: s,
+				r:          r,
+			}
+		}
+		if t.dispatched {
+			return
+		}
+	}
+}
+
+// work processes the admitted tasks.
+func (t *Throttle) work(admissions <-chan *admission) {
+	for a := range admissions {
+		s := a.submission
+		s.finish(t.run(s.ctx, a.r, costOne(s.task)))
+	}
+}
@@ -0,0 +1,90 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottleSubmit verifies the asynchronous processing of submitted
+// tasks with the limit of the throttle.
+func TestThrottleSubmit(t *testing.T) {
+	throttle := wait.NewThrottle(20, 1, wait.WithWorkers(2))
+	ctx := context.Background()
+	ouch := errors.New("ouch")
+
+	start := time.Now()
+	futures := make([]*wait.Future, 5)
+	for i := range futures {
+		futures[i] = throttle.Submit(ctx, func() error {
+			if i == 4 {
+				return ouch
+			}
+			return nil
+		})
+	}
+	verify.True(t, time.Since(start) < 10*time.Millisecond, "submit does not block")
+	verify.NoError(t, futures[0].Wait(ctx))
+	verify.Nil(t, futures[4].Err())
+
+	<-futures[4].Done()
+	verify.DurationAboutEqual(t, time.Since(start), 200*time.Millisecond, 30*time.Millisecond)
+	verify.True(t, errors.Is(futures[4].Err(), ouch))
+	for _, f := range futures[:4] {
+		verify.NoError(t, f.Err())
+	}
+}
+
+// TestThrottleSubmitContext verifies the handling of ending contexts of
+// submitted tasks and of waiting for futures.
+func TestThrottleSubmitContext(t *testing.T) {
+	throttle := wait.NewThrottle(1, 1)
+	ctx := context.Background()
+
+	verify.NoError(t, throttle.Submit(ctx, func() error { return nil }).Wait(ctx))
+
+	// The task cannot be admitted before the deadline.
+	sctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	f := throttle.Submit(sctx, func() error { return nil })
+	verify.True(t, errors.Is(f.Wait(ctx), context.DeadlineExceeded))
+
+	// Waiting for a future ends with its context.
+	f = throttle.Submit(ctx, func() error { return nil })
+	wctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	verify.ErrorContains(t, f.Wait(wctx), "wait for future")
+	verify.NoError(t, f.Wait(ctx))
+}
+
+// TestSubmitValue verifies the submitting of tasks returning values.
+func TestSubmitValue(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 0)
+	ctx := context.Background()
+
+	f := wait.SubmitValue(ctx, throttle, func() (string, error) {
+		return strconv.Itoa(42), nil
+	})
+	value, err := f.Value(ctx)
+	verify.NoError(t, err)
+	verify.Equal(t, value, "42")
+
+	f = wait.SubmitValue(ctx, throttle, func() (string, error) {
+		return "", errors.New("ouch")
+	})
+	_, err = f.Value(ctx)
+	verify.ErrorContains(t, err, "ouch")
+}
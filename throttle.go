@@ -10,8 +10,12 @@ package wait
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -20,36 +24,424 @@ import (
 // Task defines the signarure of a task to be processed.
 type Task func() error
 
+// CostTask defines the signature of a task reporting its cost in tokens
+// after being processed, e.g. the number of transferred bytes.
+type CostTask func() (cost int, err error)
+
 // Limit defines the rate limit of a throttle.
 type Limit = rate.Limit
 
 const (
 	// Inf is the infinite rate limit.
 	InfLimit = Limit(math.MaxFloat64)
+
+	// InfDuration is the estimated wait if a throttle never allows a task.
+	InfDuration = time.Duration(math.MaxInt64)
 )
 
+// ErrThrottled is returned by a Throttle rejecting a task instead of letting
+// it wait. The returned error is a *ThrottledError containing the details.
+var ErrThrottled = errors.New("throttled")
+
+// ErrRefund can be returned by a task, also wrapped, if it hasn't touched the
+// throttled resource, e.g. due to a validation error. The throttle gives the
+// token of the task back then.
+var ErrRefund = errors.New("refund throttle token")
+
+// ThrottledError is returned when a Throttle sheds load. It tells how long
+// the rejected task would have had to wait.
+type ThrottledError struct {
+	Wait time.Duration
+}
+
+// Error implements the error interface.
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("throttled: estimated wait %v", e.Wait)
+}
+
+// Is allows to check the error against ErrThrottled.
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
 // A Throttle limits the processing of tasks per second. It is configured with a
 // limit and a burst. The limit is the maximum number of tasks per second and the
 // burst the maximum number of tasks that can be processed at once. If the limit
 // is InfLimit the throttle is not limited, if it is 0 no tasks can be processed.
+// By default a token bucket is used, other Limiter algorithms can be passed.
+// Options allow to additionally limit the number of concurrently running tasks,
+// to reject tasks instead of letting them wait too long, and to admit waiting
+// tasks by their priority. The limit can adapt itself to the errors of the
+// processed tasks.
 type Throttle struct {
-	limiter *rate.Limiter
+	limiter    Limiter
+	slots      chan struct{}
+	maxQueue   int64
+	maxWait    time.Duration
+	waiting    atomic.Int64
+	gate       *gate
+	aging      time.Duration
+	fifo       bool
+	started    time.Time
+	mu         sync.Mutex
+	aimd       *AIMD
+	parallel   int
+	workers    int
+	noRecovery bool
+
+	dispatchOnce sync.Once
+	submitMu     sync.Mutex
+	submitted    []*submission
+	submittedc   chan struct{}
+	dispatched   bool
+
+	closing  context.Context
+	closeFn  context.CancelFunc
+	lifeMu   sync.Mutex
+	active   int
+	drained  int
+	rejected int
+	idle     chan struct{}
+}
+
+// ThrottleOption defines a function setting an option of a Throttle.
+type ThrottleOption func(t *Throttle)
+
+// WithMaxInFlight limits the number of tasks processed concurrently by the
+// throttle. A task has to wait for a free slot before it waits for the
+// limiter. Values less than 1 mean no limit.
+func WithMaxInFlight(max int) ThrottleOption {
+	return func(t *Throttle) {
+		if max < 1 {
+			t.slots = nil
+			return
+		}
+		t.slots = make(chan struct{}, max)
+	}
 }
 
-// NewThrottle creates a new Throttle with the specified limit and burst.
-func NewThrottle(limit Limit, burst int) *Throttle {
-	return &Throttle{
-		limiter: rate.NewLimiter(limit, burst),
+// WithMaxQueue limits the number of tasks waiting to be processed. Further
+// tasks are rejected with a *ThrottledError. Values less than 1 mean no limit.
+func WithMaxQueue(max int) ThrottleOption {
+	return func(t *Throttle) {
+		t.maxQueue = int64(max)
 	}
 }
 
+// WithMaxWait rejects tasks with a *ThrottledError if they would have to
+// wait longer than the given duration for the limiter. Values less than or
+// equal to 0 mean no limit.
+func WithMaxWait(max time.Duration) ThrottleOption {
+	return func(t *Throttle) {
+		t.maxWait = max
+	}
+}
+
+// WithPriorities lets waiting tasks be admitted by their priority as passed
+// to ProcessPriority(), higher priorities first, equal priorities in order
+// of their arrival. An aging larger than 0 protects low priorities from
+// starvation, for each aging duration a task waits its priority raises by 1.
+func WithPriorities(aging time.Duration) ThrottleOption {
+	return func(t *Throttle) {
+		t.gate = &gate{}
+		t.aging = max(aging, 0)
+		t.fifo = false
+	}
+}
+
+// WithFIFO lets waiting tasks be admitted strictly in the order of their
+// arrival. Priorities passed to ProcessPriority() are ignored then.
+func WithFIFO() ThrottleOption {
+	return func(t *Throttle) {
+		t.gate = &gate{}
+		t.fifo = true
+	}
+}
+
+// NewThrottle creates a new Throttle with the specified limit and burst using
+// a token bucket.
+func NewThrottle(limit Limit, burst int, options ...ThrottleOption) *Throttle {
+	return NewThrottleWithLimiter(NewTokenBucketLimiter(limit, burst), options...)
+}
+
+// NewThrottleWithLimiter creates a new Throttle using the given Limiter
+// algorithm.
+func NewThrottleWithLimiter(limiter Limiter, options ...ThrottleOption) *Throttle {
+	t := &Throttle{
+		limiter: limiter,
+		started: time.Now(),
+		idle:    make(chan struct{}),
+	}
+	t.closing, t.closeFn = context.WithCancel(context.Background())
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
 // Process processes a task under the context, waiting if necessary.
 func (t *Throttle) Process(ctx context.Context, task Task) error {
+	return t.ProcessPriority(ctx, 0, task)
+}
+
+// ProcessPriority processes a task with the given priority under the context,
+// waiting if necessary. The priority only matters for a throttle created with
+// the option WithPriorities(), otherwise it is ignored.
+func (t *Throttle) ProcessPriority(ctx context.Context, priority int, task Task) error {
+	return t.process(ctx, t.priorityKey(priority), costOne(task))
+}
+
+// ProcessCost processes a task whose cost is only known afterwards. Like
+// any task it needs one token to be admitted. Afterwards the throttle takes
+// the remaining tokens of the reported cost even if it goes into debt. So
+// the following tasks wait until the debt is paid. A cost of 0 gives the
+// token back.
+func (t *Throttle) ProcessCost(ctx context.Context, task CostTask) error {
+	return t.process(ctx, t.priorityKey(0), task)
+}
+
+// InFlight returns the number of tasks currently holding a slot of a
+// throttle with a limited number of concurrent tasks. Otherwise it is 0.
+func (t *Throttle) InFlight() int {
+	return len(t.slots)
+}
+
+// Waiting returns the number of tasks currently waiting to be processed.
+func (t *Throttle) Waiting() int {
+	return int(t.waiting.Load())
+}
+
+// Limit returns the current limit of the throttle.
+func (t *Throttle) Limit() Limit {
+	return t.limiter.Limit()
+}
+
+// SetLimit changes the limit of the throttle. Tasks already waiting keep
+// the point in time they have been scheduled for, all following ones are
+// processed with the new limit.
+func (t *Throttle) SetLimit(limit Limit) {
+	t.limiter.SetLimit(limit)
+}
+
+// Burst returns the current burst of the throttle.
+func (t *Throttle) Burst() int {
+	return t.limiter.Burst()
+}
+
+// SetBurst changes the burst of the throttle. Like with SetLimit() tasks
+// already waiting are not affected.
+func (t *Throttle) SetBurst(burst int) {
+	t.limiter.SetBurst(burst)
+}
+
+// Tokens returns a snapshot of the number of tokens currently available
+// for processing tasks. It may be negative if tasks are waiting.
+func (t *Throttle) Tokens() float64 {
+	return t.limiter.TokensAt(time.Now())
+}
+
+
+// process enqueues the task with the given key for the gate and processes
+// it after admission.
+func (t *Throttle) process(ctx context.Context, key float64, task CostTask) (err error) {
+	ctx, leave, err := t.enter(ctx, 1)
+	if err != nil {
+		return err
+	}
+	ran := 0
+	defer func() { leave(ran, err) }()
+	if err := t.enqueue(); err != nil {
+		return err
+	}
+	r, err := t.admit(ctx, key, t.limiter, 1)
+	if err != nil {
+		return err
+	}
+	ran, err = t.run(ctx, r, task)
+	return err
+}
+
+// run processes an admitted task and accounts its cost. Afterwards its
+// slot is freed. It returns 1 if the task has been processed, 0 if not.
+func (t *Throttle) run(ctx context.Context, r Reservation, task CostTask) (int, error) {
+	defer t.freeSlot()
+	// Give the token back if the context ended right after admission.
+	if ctx.Err() != nil {
+		refund(r, time.Now())
+		return 0, fmt.Errorf("throttle context done before task start: %w", doneErr(ctx))
+	}
+	// Process the task and account its cost. Refunded tasks haven't used
+	// the downstream service, so they don't adapt the limit.
+	cost, err := t.call(task)
+	if errors.Is(err, ErrRefund) || cost < 1 {
+		refund(r, time.Now())
+		return 1, err
+	}
+	if cost > 1 {
+		t.charge(cost - 1)
+	}
+	if t.aimd != nil {
+		t.adapt(err)
+	}
+	return 1, err
+}
+
+// enqueue adds a task to the queue. It is rejected if the queue is full.
+func (t *Throttle) enqueue() error {
+	waiting := t.waiting.Add(1)
+	if t.maxQueue > 0 && waiting > t.maxQueue {
+		t.waiting.Add(-1)
+		return &ThrottledError{Wait: t.estimate(time.Now())}
+	}
+	return nil
+}
+
+// admit lets the task wait for its turn, a free slot, and n tokens of the
+// limiter. The task is removed from the queue afterwards.
+func (t *Throttle) admit(ctx context.Context, key float64, limiter Limiter, n int) (Reservation, error) {
+	defer t.waiting.Add(-1)
+	leave, err := t.turn(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	if err := t.slot(ctx); err != nil {
+		return nil, err
+	}
 	// Wait for the limiter to allow us to proceed.
-	if err := t.limiter.Wait(ctx); err != nil {
-		return fmt.Errorf("wait for throttle limiter: %w", err)
+	r, err := reserve(ctx, limiter, n, t.maxWait)
+	if err != nil {
+		t.freeSlot()
+		return nil, err
 	}
-	// Process the task and return its error.
-	return task()
+	return r, nil
 }
 
+// turn waits for the turn if tasks are admitted in order. The returned
+// function passes the turn on.
+func (t *Throttle) turn(ctx context.Context, key float64) (func(), error) {
+	if t.gate == nil {
+		return func() {}, nil
+	}
+	if err := t.gate.enter(ctx, key); err != nil {
+		return nil, fmt.Errorf("wait for throttle turn: %w", doneErr(ctx))
+	}
+	return t.gate.leave, nil
+}
+
+// slot waits for a free slot if the number of tasks in flight is limited.
+func (t *Throttle) slot(ctx context.Context) error {
+	if t.slots == nil {
+		return nil
+	}
+	select {
+	case t.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for throttle slot: %w", doneErr(ctx))
+	}
+}
+
+// freeSlot frees the slot taken by slot().
+func (t *Throttle) freeSlot() {
+	if t.slots != nil {
+		<-t.slots
+	}
+}
+
+// reserve reserves n tokens of the limiter and waits until they can be used.
+// The tokens are given back if the wait is not possible or ends early.
+func (t *Throttle) reserve(ctx context.Context, n int) (Reservation, error) {
+	return reserve(ctx, t.limiter, n, t.maxWait)
+}
+
+// reserve reserves n tokens of the limiter and waits until they can be used.
+// Waits longer than maxWait are rejected if it is larger than 0.
+func reserve(ctx context.Context, limiter Limiter, n int, maxWait time.Duration) (Reservation, error) {
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("wait for throttle limiter: %w", doneErr(ctx))
+	default:
+	}
+	now := time.Now()
+	r := limiter.Reserve(now, n)
+	if !r.OK() {
+		if rr, ok := r.(*reservation); ok && rr.err != nil {
+			return nil, fmt.Errorf("wait for throttle limiter: %w", rr.err)
+		}
+		return nil, fmt.Errorf("wait for throttle limiter: Wait(n=%d) exceeds limiter's burst %d", n, limiter.Burst())
+	}
+	delay := r.DelayFrom(now)
+	if delay == 0 {
+		return r, nil
+	}
+	if maxWait > 0 && delay > maxWait {
+		r.CancelAt(now)
+		return nil, &ThrottledError{Wait: delay}
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		r.CancelAt(now)
+		return nil, fmt.Errorf("wait for throttle limiter: %w", context.DeadlineExceeded)
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return r, nil
+	case <-ctx.Done():
+		r.CancelAt(time.Now())
+		return nil, fmt.Errorf("wait for throttle limiter: %w", doneErr(ctx))
+	}
+}
+
+// charge takes tokens from the limiter without waiting for them. The
+// reservations are done in chunks of the burst size.
+func (t *Throttle) charge(tokens int) {
+	now := time.Now()
+	burst := max(t.limiter.Burst(), 1)
+	for tokens > 0 {
+		n := min(tokens, burst)
+		if !t.limiter.Reserve(now, n).OK() {
+			return
+		}
+		tokens -= n
+	}
+}
+
+// priorityKey returns the key for the gate of a task with the given priority.
+func (t *Throttle) priorityKey(priority int) float64 {
+	if t.fifo {
+		// Equal keys are admitted in order of arrival.
+		return 0
+	}
+	if t.aging == 0 {
+		return -float64(priority)
+	}
+	// A task with a higher priority is treated like one having arrived
+	// earlier by the aging for each priority level.
+	arrival := time.Since(t.started)
+	return arrival.Seconds() - float64(priority)*t.aging.Seconds()
+}
+
+// estimate returns the estimated wait for a task arriving at the given time.
+func (t *Throttle) estimate(now time.Time) time.Duration {
+	missing := 1 - t.limiter.TokensAt(now)
+	limit := t.limiter.Limit()
+	if wl, ok := t.limiter.(*warmingLimiter); ok {
+		limit = wl.rate(now)
+	}
+	switch {
+	case missing <= 0 || limit == InfLimit:
+		return 0
+	case limit <= 0:
+		return InfDuration
+	}
+	return time.Duration(missing / float64(limit) * float64(time.Second))
+}
+
+// costOne turns a task into one costing one token.
+func costOne(task Task) CostTask {
+	return func() (int, error) {
+		return 1, task()
+	}
+}
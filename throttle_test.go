@@ -10,7 +10,10 @@ package wait_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -156,6 +159,323 @@ func TestThrottleBurst(t *testing.T) {
 	}
 }
 
+// TestThrottleReconfiguration verifies the changing of limit and burst
+// of a throttle at runtime.
+func TestThrottleReconfiguration(t *testing.T) {
+	throttle := wait.NewThrottle(1, 1)
+	ctx := context.Background()
+
+	verify.Equal(t, throttle.Limit(), wait.Limit(1))
+	verify.Equal(t, throttle.Burst(), 1)
+	verify.AboutEqual(t, throttle.Tokens(), 1.0, 0.01)
+
+	// Use the only token, the next task would have to wait a second.
+	err := throttle.Process(ctx, func() error { return nil })
+	verify.NoError(t, err)
+	verify.AboutEqual(t, throttle.Tokens(), 0.0, 0.01)
+
+	// Raise limit and burst, now many tasks run fast.
+	throttle.SetLimit(1000)
+	throttle.SetBurst(10)
+	verify.Equal(t, throttle.Limit(), wait.Limit(1000))
+	verify.Equal(t, throttle.Burst(), 10)
+
+	start := time.Now()
+	for range 100 {
+		err := throttle.Process(ctx, func() error { return nil })
+		verify.NoError(t, err)
+	}
+	verify.Shorter(t, time.Since(start), 500*time.Millisecond)
+
+	// Reconfigure concurrently to processing tasks.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			throttle.SetLimit(wait.Limit(500 + i*10))
+			throttle.SetBurst(1 + i%10)
+			throttle.Tokens()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			err := throttle.Process(ctx, func() error { return nil })
+			verify.NoError(t, err)
+		}
+	}()
+	wg.Wait()
+	verify.Equal(t, throttle.Limit(), wait.Limit(1490))
+	verify.Equal(t, throttle.Burst(), 10)
+}
+
+// TestThrottleMaxInFlight verifies the limitation of concurrently
+// processed tasks.
+func TestThrottleMaxInFlight(t *testing.T) {
+	throttle := wait.NewThrottle(wait.InfLimit, 100, wait.WithMaxInFlight(3))
+	ctx := context.Background()
+	cc := &concurrencyCounter{}
+	task := func() error {
+		cc.incr()
+		defer cc.decr()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	var wg sync.WaitGroup
+	wg.Add(20)
+	for range 20 {
+		go func() {
+			defer wg.Done()
+			err := throttle.Process(ctx, task)
+			verify.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	verify.Equal(t, cc.max(), 3, "maximum number of parallel goroutines defined by max in flight")
+	verify.Equal(t, throttle.InFlight(), 0)
+
+	// Waiting for a slot honours the context.
+	throttle = wait.NewThrottle(wait.InfLimit, 1, wait.WithMaxInFlight(1))
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		throttle.Process(ctx, func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	verify.Equal(t, throttle.InFlight(), 1)
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err := throttle.Process(tctx, task)
+	verify.ErrorContains(t, err, "wait for throttle slot")
+	close(release)
+}
+
+// TestThrottleMaxQueue verifies the rejection of tasks if too many
+// are waiting.
+func TestThrottleMaxQueue(t *testing.T) {
+	throttle := wait.NewThrottle(10, 1, wait.WithMaxQueue(2))
+	ctx := context.Background()
+	task := func() error { return nil }
+
+	// Use the token, so that the next tasks have to wait.
+	verify.NoError(t, throttle.Process(ctx, task))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for range 2 {
+		go func() {
+			defer wg.Done()
+			verify.NoError(t, throttle.Process(ctx, task))
+		}()
+	}
+	err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+		return throttle.Waiting() == 2, nil
+	})
+	verify.NoError(t, err)
+
+	// Queue is full, so the next task is rejected.
+	err = throttle.Process(ctx, task)
+	verify.True(t, errors.Is(err, wait.ErrThrottled))
+	var terr *wait.ThrottledError
+	verify.True(t, errors.As(err, &terr))
+	verify.Longer(t, terr.Wait, 0)
+
+	wg.Wait()
+	verify.Equal(t, throttle.Waiting(), 0)
+}
+
+// TestThrottleMaxWait verifies the rejection of tasks which would have
+// to wait too long.
+func TestThrottleMaxWait(t *testing.T) {
+	throttle := wait.NewThrottle(10, 1, wait.WithMaxWait(50*time.Millisecond))
+	ctx := context.Background()
+	task := func() error { return nil }
+
+	verify.NoError(t, throttle.Process(ctx, task))
+
+	// The next token is available in 100ms.
+	err := throttle.Process(ctx, task)
+	verify.True(t, errors.Is(err, wait.ErrThrottled))
+	var terr *wait.ThrottledError
+	verify.True(t, errors.As(err, &terr))
+	verify.DurationAboutEqual(t, terr.Wait, 100*time.Millisecond, 10*time.Millisecond)
+
+	// Rejection gave the token back, so after 60ms we only wait 40ms.
+	time.Sleep(60 * time.Millisecond)
+	start := time.Now()
+	verify.NoError(t, throttle.Process(ctx, task))
+	verify.DurationAboutEqual(t, time.Since(start), 40*time.Millisecond, 15*time.Millisecond)
+}
+
+// TestThrottlePriorities verifies the admission of waiting tasks by
+// their priorities.
+func TestThrottlePriorities(t *testing.T) {
+	tests := []struct {
+		name     string
+		aging    time.Duration
+		expected string
+	}{
+		{
+			name:     "strict priorities",
+			expected: "lHHHll",
+		},
+		{
+			name:     "aging lets low priorities catch up",
+			aging:    5 * time.Millisecond,
+			expected: "lllHHH",
+		},
+	}
+	for _, test := range tests {
+		t.Logf("test: %s", test.name)
+		throttle := wait.NewThrottle(10, 1, wait.WithPriorities(test.aging))
+		ctx := context.Background()
+		var mu sync.Mutex
+		order := ""
+		task := func(id string) wait.Task {
+			return func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				order += id
+				return nil
+			}
+		}
+		// Use the token, so that the next tasks have to wait.
+		verify.NoError(t, throttle.Process(ctx, task("")))
+
+		var wg sync.WaitGroup
+		enqueued := 0
+		enqueue := func(id string, priority int) {
+			wg.Add(1)
+			enqueued++
+			go func() {
+				defer wg.Done()
+				verify.NoError(t, throttle.ProcessPriority(ctx, priority, task(id)))
+			}()
+			err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+				return throttle.Waiting() == enqueued, nil
+			})
+			verify.NoError(t, err)
+		}
+		for range 3 {
+			enqueue("l", 0)
+		}
+		time.Sleep(10 * time.Millisecond)
+		for range 3 {
+			enqueue("H", 1)
+		}
+		wg.Wait()
+		verify.Equal(t, order, test.expected)
+	}
+}
+
+// TestThrottleFIFO verifies the admission of many concurrently waiting
+// tasks in order of their arrival.
+func TestThrottleFIFO(t *testing.T) {
+	throttle := wait.NewThrottle(100, 1, wait.WithFIFO())
+	ctx := context.Background()
+	var mu sync.Mutex
+	var order []int
+	var done atomic.Int64
+
+	// Use the token, so that the next tasks have to wait.
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Priorities are ignored.
+			verify.NoError(t, throttle.ProcessPriority(ctx, i%3, func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, i)
+				done.Add(1)
+				return nil
+			}))
+		}()
+		err := wait.WithTimeout(ctx, 50*time.Microsecond, time.Second, func() (bool, error) {
+			return int64(throttle.Waiting())+done.Load() > int64(i), nil
+		})
+		verify.NoError(t, err)
+		// Give the task the time to queue up.
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+	verify.Length(t, order, 50)
+	for i, id := range order {
+		verify.Equal(t, id, i)
+	}
+}
+
+// TestThrottleRefund verifies the giving back of tokens by tasks not
+// touching the throttled resource or cancelled before their start.
+func TestThrottleRefund(t *testing.T) {
+	throttle := wait.NewThrottle(1, 3)
+	ctx := context.Background()
+	invalid := func() error {
+		return fmt.Errorf("%w: invalid input", wait.ErrRefund)
+	}
+
+	for range 5 {
+		err := throttle.Process(ctx, invalid)
+		verify.True(t, errors.Is(err, wait.ErrRefund))
+	}
+	verify.AboutEqual(t, throttle.Tokens(), 3.0, 0.01)
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	verify.AboutEqual(t, throttle.Tokens(), 2.0, 0.01)
+
+	// Refunds also work with other limiters.
+	throttle = wait.NewThrottleWithLimiter(wait.NewSlidingWindowLogLimiter(1, time.Hour))
+	verify.ErrorContains(t, throttle.Process(ctx, invalid), "invalid input")
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	verify.Equal(t, throttle.Tokens(), 0.0)
+
+	// Cancelling a waiting task gives its token back.
+	throttle = wait.NewThrottle(10, 1)
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+	}()
+	err := throttle.Process(cctx, func() error { return nil })
+	verify.ErrorContains(t, err, "context canceled")
+	start := time.Now()
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	verify.DurationAboutEqual(t, time.Since(start), 70*time.Millisecond, 15*time.Millisecond)
+}
+
+// TestThrottleProcessCost verifies the debt of tasks reporting their
+// cost after being processed.
+func TestThrottleProcessCost(t *testing.T) {
+	throttle := wait.NewThrottle(1000, 10)
+	ctx := context.Background()
+
+	// A cost of 0 gives the token back.
+	err := throttle.ProcessCost(ctx, func() (int, error) { return 0, nil })
+	verify.NoError(t, err)
+	verify.AboutEqual(t, throttle.Tokens(), 10.0, 0.5)
+
+	// A cost of 110 leaves a debt of 100 tokens.
+	err = throttle.ProcessCost(ctx, func() (int, error) { return 110, nil })
+	verify.NoError(t, err)
+	verify.True(t, throttle.Tokens() < -90, "throttle in debt")
+	start := time.Now()
+	verify.NoError(t, throttle.Process(ctx, func() error { return nil }))
+	verify.DurationAboutEqual(t, time.Since(start), 100*time.Millisecond, 20*time.Millisecond)
+
+	// Errors are returned and the cost is charged anyway.
+	err = throttle.ProcessCost(ctx, func() (int, error) { return 50, errors.New("ouch") })
+	verify.ErrorContains(t, err, "ouch")
+	verify.True(t, throttle.Tokens() < -40, "throttle in debt")
+}
+
 
 // concurrencyCounter is a helper to count the maximum number of
 // parallel running goroutines.
@@ -93,7 +93,7 @@ func Poll(ctx context.Context, ticker TickerFunc, condition ConditionFunc) error
 			ok, err := check(condition)
 			if err != nil {
 				// ConditionFunc has an error.
-				return fmt.Errorf("poll condition returned error: %v", err)
+				return fmt.Errorf("poll condition returned error: %w", err)
 			}
 			if ok {
 				// ConditionFunc is happy.
@@ -159,12 +159,12 @@ func WithTimeout(
 
 
 // check runs the condition catching potential panics and returns
-// them as failure.
+// them as *PanicError.
 func check(condition ConditionFunc) (ok bool, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			ok = false
-			err = fmt.Errorf("panic during condition check: %v", r)
+			err = newPanicError("condition check", r)
 		}
 	}()
 	ok, err = condition()
@@ -10,6 +10,7 @@ package wait_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -409,6 +410,20 @@ func TestPanic(t *testing.T) {
 	verify.Equal(t, count, 5)
 }
 
+// TestPanicError tests the details of panics during condition checks.
+func TestPanicError(t *testing.T) {
+	ouch := errors.New("ouch")
+	err := wait.WithInterval(context.Background(), 10*time.Millisecond, func() (bool, error) {
+		panic(ouch)
+	})
+	var perr *wait.PanicError
+	verify.True(t, errors.As(err, &perr))
+	verify.Equal(t, perr.Value, any(ouch))
+	verify.Contains(t, string(perr.Stack), "TestPanicError")
+	verify.True(t, errors.Is(err, ouch))
+	verify.ErrorContains(t, err, "panic during condition check: ouch")
+}
+
 
 // mkChgTicker creates a ticker with a changing interval.
 func mkChgTicker() wait.TickerFunc {
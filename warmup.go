@@ -0,0 +1,147 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// WithWarmUp lets the throttle start with a limit reduced by the cold factor
+// and a burst of 1. Both ramp up linearly to the configured ones during the
+// warm-up period. If the throttle hasn't been used for the idle duration it
+// warms up again. An idle duration of 0 means that it only warms up once.
+// During the warm-up Burst() of the throttle returns the reduced burst, while
+// Limit() returns the configured limit, so that changes relative to it like
+// the ones of WithAIMD() change the limit to ramp up to.
+func WithWarmUp(period time.Duration, coldFactor float64, idle time.Duration) ThrottleOption {
+	return func(t *Throttle) {
+		t.limiter = &warmingLimiter{
+			limiter:    t.limiter,
+			period:     period,
+			coldFactor: max(coldFactor, 1),
+			idle:       idle,
+			limit:      t.limiter.Limit(),
+			burst:      t.limiter.Burst(),
+		}
+	}
+}
+
+// warmingLimiter wraps a Limiter and ramps its limit and burst up.
+type warmingLimiter struct {
+	mu         sync.Mutex
+	limiter    Limiter
+	period     time.Duration
+	coldFactor float64
+	idle       time.Duration
+	limit      Limit
+	burst      int
+	started    time.Time
+	used       time.Time
+}
+
+func (wl *warmingLimiter) Reserve(now time.Time, n int) Reservation {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	wl.started = wl.start(now)
+	wl.used = now
+	wl.ramp(now)
+	return wl.limiter.Reserve(now, n)
+}
+
+func (wl *warmingLimiter) TokensAt(now time.Time) float64 {
+	return wl.limiter.TokensAt(now)
+}
+
+// Limit returns the configured limit, not the reduced one during the
+// warm-up.
+func (wl *warmingLimiter) Limit() Limit {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	return wl.limit
+}
+
+func (wl *warmingLimiter) SetLimit(limit Limit) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	wl.limit = limit
+	wl.ramp(time.Now())
+}
+
+// Burst returns the burst a reservation gets now, it's reduced during
+// the warm-up.
+func (wl *warmingLimiter) Burst() int {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	now := time.Now()
+	_, burst := wl.warm(wl.start(now), now)
+	return burst
+}
+
+func (wl *warmingLimiter) SetBurst(burst int) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	wl.burst = burst
+	wl.ramp(time.Now())
+}
+
+
+// rate returns the limit a reservation gets now, it's reduced during the
+// warm-up.
+func (wl *warmingLimiter) rate(now time.Time) Limit {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	limit, _ := wl.warm(wl.start(now), now)
+	return limit
+}
+
+// start returns the start of the warm-up for a use at now. It starts again
+// if the limiter hasn't been used for the idle duration. The mutex has to be
+// locked.
+func (wl *warmingLimiter) start(now time.Time) time.Time {
+	if wl.started.IsZero() || (wl.idle > 0 && now.Sub(wl.used) > wl.idle) {
+		return now
+	}
+	return wl.started
+}
+
+// warm returns limit and burst at now for a warm-up started at the given
+// time. The mutex has to be locked.
+func (wl *warmingLimiter) warm(started, now time.Time) (Limit, int) {
+	progress := 1.0
+	if !started.IsZero() && wl.period > 0 {
+		progress = min(float64(now.Sub(started))/float64(wl.period), 1.0)
+	}
+	limit := wl.limit
+	burst := wl.burst
+	if progress < 1 && limit != InfLimit {
+		cold := float64(limit) / wl.coldFactor
+		limit = Limit(cold + (float64(limit)-cold)*progress)
+		burst = min(burst, max(int(math.Round(float64(burst)*progress)), 1))
+	}
+	return limit, burst
+}
+
+// ramp sets limit and burst of the wrapped limiter according to the
+// progress of the warm-up. The mutex has to be locked.
+func (wl *warmingLimiter) ramp(now time.Time) {
+	limit, burst := wl.warm(wl.started, now)
+	if wl.limiter.Limit() != limit {
+		wl.limiter.SetLimit(limit)
+	}
+	if wl.limiter.Burst() != burst {
+		wl.limiter.SetBurst(burst)
+	}
+}
@@ -0,0 +1,100 @@
+// Tideland Go Wait - Unit Tests
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/asserts/verify"
+
+	"tideland.dev/go/wait"
+)
+
+// TestThrottleWarmUp verifies the ramp up of the admitted rate.
+func TestThrottleWarmUp(t *testing.T) {
+	throttle := wait.NewThrottle(100, 10, wait.WithWarmUp(500*time.Millisecond, 5, 200*time.Millisecond))
+	ctx := context.Background()
+
+	verify.Equal(t, throttle.Limit(), wait.Limit(100))
+	verify.Equal(t, throttle.Burst(), 1, "cold burst")
+
+	// curve returns the number of admitted tasks per 100ms.
+	curve := func(slices int) []int {
+		counts := make([]int, slices)
+		start := time.Now()
+		for {
+			err := throttle.Process(ctx, func() error { return nil })
+			verify.NoError(t, err)
+			slice := int(time.Since(start) / (100 * time.Millisecond))
+			if slice >= slices {
+				return counts
+			}
+			counts[slice]++
+		}
+	}
+
+	counts := curve(7)
+	t.Logf("warm-up curve: %v", counts)
+	verify.InRange(t, counts[0], 1, 5, "cold start")
+	verify.InRange(t, counts[6], 9, 11, "configured limit")
+	for i := 1; i < len(counts); i++ {
+		verify.True(t, counts[i] >= counts[i-1]-1, "rising rate")
+	}
+
+	// After being idle the throttle warms up again.
+	time.Sleep(250 * time.Millisecond)
+	counts = curve(2)
+	t.Logf("curve after idle: %v", counts)
+	verify.InRange(t, counts[0], 1, 5, "cold start after idle")
+}
+
+// TestThrottleWarmUpProcessAll verifies that batches fit into the burst
+// during the warm-up.
+func TestThrottleWarmUpProcessAll(t *testing.T) {
+	throttle := wait.NewThrottle(100, 10, wait.WithWarmUp(200*time.Millisecond, 2, 0))
+	ctx := context.Background()
+	tasks := make([]wait.Task, 30)
+	for i := range tasks {
+		tasks[i] = func() error { return nil }
+	}
+
+	verify.NoError(t, throttle.ProcessAll(ctx, tasks...))
+	time.Sleep(250 * time.Millisecond)
+	verify.Equal(t, throttle.Burst(), 10, "configured burst")
+	verify.NoError(t, throttle.ProcessAll(ctx, tasks...))
+}
+
+// TestThrottleWarmUpEstimate verifies the estimated wait of rejected tasks
+// with the reduced limit during the warm-up.
+func TestThrottleWarmUpEstimate(t *testing.T) {
+	throttle := wait.NewThrottle(100, 1, wait.WithWarmUp(10*time.Second, 10, 0), wait.WithMaxQueue(1))
+	ctx := context.Background()
+	task := func() error { return nil }
+
+	verify.NoError(t, throttle.Process(ctx, task))
+	verify.Equal(t, throttle.Limit(), wait.Limit(100), "configured limit")
+	verify.Equal(t, throttle.Burst(), 1)
+
+	// The queued task waits about 100ms with a cold limit of 10.
+	done := make(chan error)
+	go func() {
+		done <- throttle.Process(ctx, task)
+	}()
+	err := wait.WithTimeout(ctx, time.Millisecond, time.Second, func() (bool, error) {
+		return throttle.Waiting() == 1, nil
+	})
+	verify.NoError(t, err)
+	err = throttle.Process(ctx, task)
+	var terr *wait.ThrottledError
+	verify.True(t, errors.As(err, &terr))
+	verify.InRange(t, terr.Wait, 100*time.Millisecond, 250*time.Millisecond)
+	verify.NoError(t, <-done)
+}
@@ -0,0 +1,459 @@
+// Tideland Go Wait
+//
+// Copyright (C) 2019-2025 Frank Mueller / Tideland / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package wait
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// NewFixedWindowLimiter returns a Limiter allowing burst tokens per window.
+// The windows are aligned to the Unix epoch, at the start of each window
+// all tokens are available again. So up to twice the burst may be used
+// around the border of two windows.
+func NewFixedWindowLimiter(burst int, window time.Duration) Limiter {
+	return &fixedWindow{
+		windows: newWindows(burst, window),
+		counts:  make(map[int64]int),
+	}
+}
+
+// NewSlidingWindowLogLimiter returns a Limiter allowing burst tokens in
+// any window of the given duration. It logs the time of each used token,
+// so it is exact but needs memory for up to burst timestamps.
+func NewSlidingWindowLogLimiter(burst int, window time.Duration) Limiter {
+	return &slidingWindowLog{
+		windows: newWindows(burst, window),
+	}
+}
+
+// NewSlidingWindowCounterLimiter returns a Limiter allowing approximately
+// burst tokens in any window of the given duration. It counts the tokens per
+// fixed window and weights the count of the previous window by its overlap
+// with the sliding one.
+func NewSlidingWindowCounterLimiter(burst int, window time.Duration) Limiter {
+	return &slidingWindowCounter{
+		windows: newWindows(burst, window),
+		counts:  make(map[int64]int),
+	}
+}
+
+// windows contains the configuration shared by the window based limiters.
+// The limit is expressed by the size of the windows. A size of 0 means
+// no limit, InfDuration one endless window.
+type windows struct {
+	burst int
+	size  time.Duration
+}
+
+// newWindows creates the configuration for burst tokens per window.
+func newWindows(burst int, window time.Duration) windows {
+	if window <= 0 {
+		return windows{burst: burst}
+	}
+	return windows{burst: burst, size: window}
+}
+
+// limit returns the limit expressed by the burst and the window size.
+func (w *windows) limit() Limit {
+	switch w.size {
+	case 0:
+		return InfLimit
+	case InfDuration:
+		return 0
+	}
+	return Limit(float64(w.burst) / w.size.Seconds())
+}
+
+// setLimit changes the window size for the new limit keeping the burst.
+func (w *windows) setLimit(limit Limit) {
+	switch {
+	case limit == InfLimit:
+		w.size = 0
+	case limit <= 0:
+		w.size = InfDuration
+	default:
+		w.size = max(time.Duration(float64(w.burst)/float64(limit)*float64(time.Second)), 1)
+	}
+}
+
+// setBurst changes the burst and the window size keeping the limit. A burst
+// of 0 has no limit to keep, so the window size stays for the next burst.
+func (w *windows) setBurst(burst int) {
+	limit := w.limit()
+	keep := w.burst > 0
+	w.burst = max(burst, 0)
+	if keep && w.burst > 0 && w.size != 0 && w.size != InfDuration {
+		w.setLimit(limit)
+	}
+}
+
+// index returns the index of the window containing the given time.
+func (w *windows) index(t time.Time) int64 {
+	if w.size == 0 || w.size == InfDuration {
+		return 0
+	}
+	return t.UnixNano() / int64(w.size)
+}
+
+// start returns the start time of the window with the given index.
+func (w *windows) start(index int64) time.Time {
+	return time.Unix(0, index*int64(w.size))
+}
+
+// rebase moves the counts of the current and future windows into the
+// current window after a change of the window size.
+func (w *windows) rebase(counts map[int64]int, current int64, now time.Time) {
+	total := 0
+	for index, count := range counts {
+		if index >= current {
+			total += count
+		}
+		delete(counts, index)
+	}
+	if total > 0 {
+		counts[w.index(now)] = total
+	}
+}
+
+// fixedWindow implements the fixed window counter algorithm.
+type fixedWindow struct {
+	mu sync.Mutex
+	windows
+	counts map[int64]int
+}
+
+func (fw *fixedWindow) Reserve(now time.Time, n int) Reservation {
+	return fw.reserveFrom(now, now, n)
+}
+
+func (fw *fixedWindow) reserveFrom(now, from time.Time, n int) Reservation {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	switch {
+	case fw.size == 0:
+		return &reservation{ok: true, at: from}
+	case n > fw.burst:
+		return &reservation{}
+	}
+	current := fw.index(now)
+	for index := range fw.counts {
+		if index < current {
+			delete(fw.counts, index)
+		}
+	}
+	index := fw.index(from)
+	for fw.counts[index]+n > fw.burst {
+		if fw.size == InfDuration {
+			return &reservation{}
+		}
+		index++
+	}
+	fw.counts[index] += n
+	at := from
+	if start := fw.start(index); start.After(from) {
+		at = start
+	}
+	return &reservation{
+		ok: true,
+		at: at,
+		cancel: func(_ time.Time) {
+			fw.mu.Lock()
+			defer fw.mu.Unlock()
+			if fw.counts[index] >= n {
+				fw.counts[index] -= n
+			}
+		},
+	}
+}
+
+func (fw *fixedWindow) TokensAt(now time.Time) float64 {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if fw.size == 0 {
+		return float64(fw.burst)
+	}
+	return float64(fw.burst - fw.counts[fw.index(now)])
+}
+
+func (fw *fixedWindow) Limit() Limit {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	return fw.limit()
+}
+
+func (fw *fixedWindow) SetLimit(limit Limit) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	now := time.Now()
+	current := fw.index(now)
+	fw.setLimit(limit)
+	fw.rebase(fw.counts, current, now)
+}
+
+func (fw *fixedWindow) Burst() int {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	return fw.burst
+}
+
+func (fw *fixedWindow) SetBurst(burst int) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	now := time.Now()
+	current := fw.index(now)
+	fw.setBurst(burst)
+	fw.rebase(fw.counts, current, now)
+}
+
+// slidingWindowLog implements the sliding window log algorithm.
+type slidingWindowLog struct {
+	mu sync.Mutex
+	windows
+	log []time.Time
+}
+
+func (swl *slidingWindowLog) Reserve(now time.Time, n int) Reservation {
+	return swl.reserveFrom(now, now, n)
+}
+
+func (swl *slidingWindowLog) reserveFrom(now, from time.Time, n int) Reservation {
+	swl.mu.Lock()
+	defer swl.mu.Unlock()
+
+	switch {
+	case swl.size == 0:
+		return &reservation{ok: true, at: from}
+	case n > swl.burst:
+		return &reservation{}
+	}
+	swl.prune(now)
+	if swl.size == InfDuration && len(swl.log)+n > swl.burst {
+		return &reservation{}
+	}
+	// Each token is logged at the earliest time where the window before
+	// contains less than burst tokens, but not before already logged ones.
+	ats := make([]time.Time, n)
+	at := from
+	for i := range n {
+		if last := len(swl.log) - 1; last >= 0 && swl.log[last].After(at) {
+			at = swl.log[last]
+		}
+		if len(swl.log) >= swl.burst {
+			if free := swl.log[len(swl.log)-swl.burst].Add(swl.size); free.After(at) {
+				at = free
+			}
+		}
+		swl.log = append(swl.log, at)
+		ats[i] = at
+	}
+	return &reservation{
+		ok: true,
+		at: at,
+		cancel: func(_ time.Time) {
+			swl.mu.Lock()
+			defer swl.mu.Unlock()
+			for _, at := range ats {
+				if i := slices.IndexFunc(swl.log, at.Equal); i >= 0 {
+					swl.log = slices.Delete(swl.log, i, i+1)
+				}
+			}
+		},
+	}
+}
+
+func (swl *slidingWindowLog) TokensAt(now time.Time) float64 {
+	swl.mu.Lock()
+	defer swl.mu.Unlock()
+
+	if swl.size == 0 {
+		return float64(swl.burst)
+	}
+	cut := now.Add(-swl.size)
+	used := 0
+	for _, at := range swl.log {
+		if swl.size == InfDuration || at.After(cut) {
+			used++
+		}
+	}
+	return float64(swl.burst - used)
+}
+
+func (swl *slidingWindowLog) Limit() Limit {
+	swl.mu.Lock()
+	defer swl.mu.Unlock()
+
+	return swl.limit()
+}
+
+func (swl *slidingWindowLog) SetLimit(limit Limit) {
+	swl.mu.Lock()
+	defer swl.mu.Unlock()
+
+	swl.setLimit(limit)
+}
+
+func (swl *slidingWindowLog) Burst() int {
+	swl.mu.Lock()
+	defer swl.mu.Unlock()
+
+	return swl.burst
+}
+
+func (swl *slidingWindowLog) SetBurst(burst int) {
+	swl.mu.Lock()
+	defer swl.mu.Unlock()
+
+	swl.setBurst(burst)
+}
+
+// prune removes the log entries having left the window. The mutex has
+// to be locked.
+func (swl *slidingWindowLog) prune(now time.Time) {
+	if swl.size == InfDuration {
+		return
+	}
+	cut := now.Add(-swl.size)
+	i := 0
+	for i < len(swl.log) && !swl.log[i].After(cut) {
+		i++
+	}
+	swl.log = slices.Delete(swl.log, 0, i)
+}
+
+// slidingWindowCounter implements the sliding window counter algorithm.
+type slidingWindowCounter struct {
+	mu sync.Mutex
+	windows
+	counts map[int64]int
+}
+
+func (swc *slidingWindowCounter) Reserve(now time.Time, n int) Reservation {
+	return swc.reserveFrom(now, now, n)
+}
+
+func (swc *slidingWindowCounter) reserveFrom(now, from time.Time, n int) Reservation {
+	swc.mu.Lock()
+	defer swc.mu.Unlock()
+
+	switch {
+	case swc.size == 0:
+		return &reservation{ok: true, at: from}
+	case n > swc.burst:
+		return &reservation{}
+	case swc.size == InfDuration:
+		if swc.counts[0]+n > swc.burst {
+			return &reservation{}
+		}
+		swc.counts[0] += n
+		return &reservation{
+			ok: true,
+			at: from,
+			cancel: func(_ time.Time) {
+				swc.mu.Lock()
+				defer swc.mu.Unlock()
+				swc.counts[0] -= n
+			},
+		}
+	}
+	previous := swc.index(now) - 1
+	for index := range swc.counts {
+		if index < previous {
+			delete(swc.counts, index)
+		}
+	}
+	// Search the earliest time where the weighted count allows n tokens.
+	at := from
+	for {
+		index := swc.index(at)
+		start := swc.start(index)
+		current := swc.counts[index]
+		if current+n > swc.burst {
+			at = start.Add(swc.size)
+			continue
+		}
+		prev := float64(swc.counts[index-1])
+		weight := 1 - float64(at.Sub(start))/float64(swc.size)
+		if prev*weight+float64(current+n) <= float64(swc.burst)+1e-9 {
+			break
+		}
+		need := 1 - float64(swc.burst-current-n)/prev
+		at = start.Add(time.Duration(math.Ceil(need * float64(swc.size))))
+	}
+	index := swc.index(at)
+	swc.counts[index] += n
+	return &reservation{
+		ok: true,
+		at: at,
+		cancel: func(_ time.Time) {
+			swc.mu.Lock()
+			defer swc.mu.Unlock()
+			if swc.counts[index] >= n {
+				swc.counts[index] -= n
+			}
+		},
+	}
+}
+
+func (swc *slidingWindowCounter) TokensAt(now time.Time) float64 {
+	swc.mu.Lock()
+	defer swc.mu.Unlock()
+
+	switch swc.size {
+	case 0:
+		return float64(swc.burst)
+	case InfDuration:
+		return float64(swc.burst - swc.counts[0])
+	}
+	index := swc.index(now)
+	weight := 1 - float64(now.Sub(swc.start(index)))/float64(swc.size)
+	used := float64(swc.counts[index-1])*weight + float64(swc.counts[index])
+	return float64(swc.burst) - used
+}
+
+func (swc *slidingWindowCounter) Limit() Limit {
+	swc.mu.Lock()
+	defer swc.mu.Unlock()
+
+	return swc.limit()
+}
+
+func (swc *slidingWindowCounter) SetLimit(limit Limit) {
+	swc.mu.Lock()
+	defer swc.mu.Unlock()
+
+	now := time.Now()
+	current := swc.index(now)
+	swc.setLimit(limit)
+	swc.rebase(swc.counts, current, now)
+}
+
+func (swc *slidingWindowCounter) Burst() int {
+	swc.mu.Lock()
+	defer swc.mu.Unlock()
+
+	return swc.burst
+}
+
+func (swc *slidingWindowCounter) SetBurst(burst int) {
+	swc.mu.Lock()
+	defer swc.mu.Unlock()
+
+	now := time.Now()
+	current := swc.index(now)
+	swc.setBurst(burst)
+	swc.rebase(swc.counts, current, now)
+}